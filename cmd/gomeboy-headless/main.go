@@ -0,0 +1,281 @@
+// The gomeboy-headless runs a ROM without a window
+// and saves the selected frames as PNG files.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"gomeboy/internal/camera"
+	"gomeboy/internal/emulator"
+	"gomeboy/internal/movie"
+	"gomeboy/internal/serial"
+	"gomeboy/internal/testrom"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type options struct {
+	frames          int
+	outDir          string
+	shots           map[int]bool
+	every           int
+	untilStill      int
+	savPath         string
+	dmgBoot         string
+	cgbBoot         string
+	mapper          string
+	renderer        string
+	isPPUMemoryFree bool
+	camera          string
+	moviePath       string
+	isSerial        bool
+	isTestROM       bool
+	isRumble        bool
+	tiltX           float64
+	tiltY           float64
+}
+
+// Exit codes of the test ROM mode
+const (
+	exitPassed   = 0
+	exitFailed   = 1
+	exitTimedOut = 3
+)
+
+func main() {
+	opts, romPath := parseFlags()
+
+	rom, err := os.ReadFile(romPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var mov *movie.Movie
+	if opts.moviePath != "" {
+		if mov, err = movie.Load(opts.moviePath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var sav []byte
+	if opts.savPath != "" {
+		if mov != nil && mov.IsFromPowerOn() {
+			log.Print("-sav is ignored (the movie starts from power-on)")
+		} else if sav, err = os.ReadFile(opts.savPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(opts.outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	var emuOpts emulator.Options
+	if opts.dmgBoot != "" {
+		if emuOpts.DMGBootROM, err = os.ReadFile(opts.dmgBoot); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.cgbBoot != "" {
+		if emuOpts.CGBBootROM, err = os.ReadFile(opts.cgbBoot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	emuOpts.Mapper = opts.mapper
+	emuOpts.Renderer = opts.renderer
+	emuOpts.IsPPUMemoryFree = opts.isPPUMemoryFree
+
+	emu, err := emulator.NewEmulator(rom, sav, emuOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	base := strings.TrimSuffix(filepath.Base(romPath), filepath.Ext(romPath))
+
+	var serialOut io.Writer
+	if opts.isSerial {
+		serialOut = os.Stdout
+	}
+	var checker *testrom.Checker
+	if opts.isTestROM {
+		checker = testrom.NewChecker(emu, serialOut)
+	} else if serialOut != nil {
+		emu.CPU.Bus.Serial.Peer = &serial.CapturePeer{W: serialOut}
+	}
+	var rumbleLog *emulator.RumbleLog
+	if opts.isRumble {
+		if rumbleLog = emulator.NewRumbleLog(emu); rumbleLog == nil {
+			log.Print("-rumble is ignored (the cartridge has no rumble motor)")
+		}
+	}
+	if opts.camera != "" {
+		src, err := camera.NewSource(opts.camera)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !emu.SetCameraSource(src) {
+			log.Print("-camera is ignored (the cartridge is not a camera)")
+		}
+	}
+	if opts.tiltX != 0 || opts.tiltY != 0 {
+		if !emu.SetTilt(opts.tiltX, opts.tiltY) {
+			log.Print("-tilt is ignored (the cartridge has no accelerometer)")
+		}
+	}
+	if mov != nil {
+		if err := emu.PlayMovie(mov); err != nil {
+			log.Fatal(err)
+		}
+	}
+	result, frame, err := runFrames(emu, checker, opts, func(screen *image.RGBA, frame int) {
+		writeFrame(screen, opts.outDir, base, frame)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The last frame is always saved.
+	writeFrame(emu.CPU.Bus.PPU.GetGameScreen(), opts.outDir, base, frame)
+
+	if rumbleLog != nil {
+		for _, ev := range rumbleLog.Events {
+			state := "off"
+			if ev.IsOn {
+				state = "on"
+			}
+			fmt.Printf("rumble: frame %d %s\n", ev.Frame, state)
+		}
+	}
+
+	if checker != nil {
+		if opts.isSerial {
+			fmt.Println()
+		}
+		fmt.Printf("%s: %s (frame %d)\n", base, result, frame)
+		os.Exit(exitCode(result))
+	}
+}
+
+// The runFrames runs up to opts.frames frames and passes the frames selected by
+// -shot and -every to save. It stops early when the checker gets the result
+// or the screen has been still for opts.untilStill frames.
+// It returns the result (TimedOut unless the checker got one) and the number of the last frame run.
+func runFrames(emu *emulator.Emulator, checker *testrom.Checker, opts *options, save func(screen *image.RGBA, frame int)) (testrom.Result, int, error) {
+	result := testrom.Running
+	var prevScreen []byte
+	stillFrames := 0
+	lastFrame := 0
+	for frame := 1; frame <= opts.frames; frame++ {
+		if emu.RunFrame() == -1 {
+			return result, lastFrame, fmt.Errorf("CPU panicked at frame %d", frame)
+		}
+		lastFrame = frame
+		screen := emu.CPU.Bus.PPU.GetGameScreen()
+
+		if checker != nil {
+			if result = checker.Check(); result != testrom.Running {
+				break
+			}
+		}
+
+		if opts.shots[frame] || (opts.every > 0 && frame%opts.every == 0) {
+			save(screen, frame)
+		}
+
+		// Stop when the screen has not changed for the specified number of frames.
+		if opts.untilStill > 0 {
+			if bytes.Equal(prevScreen, screen.Pix) {
+				stillFrames++
+			} else {
+				stillFrames = 0
+				prevScreen = append(prevScreen[:0], screen.Pix...)
+			}
+			if stillFrames >= opts.untilStill {
+				break
+			}
+		}
+	}
+	if result == testrom.Running {
+		result = testrom.TimedOut
+	}
+	return result, lastFrame, nil
+}
+
+// The exitCode maps the test ROM result to the exit code of -test-rom.
+func exitCode(result testrom.Result) int {
+	switch result {
+	case testrom.Passed:
+		return exitPassed
+	case testrom.Failed:
+		return exitFailed
+	default:
+		return exitTimedOut
+	}
+}
+
+func parseFlags() (*options, string) {
+	opts := &options{}
+	var shots string
+	flag.IntVar(&opts.frames, "frames", 600, "maximum number of frames to run")
+	flag.StringVar(&opts.outDir, "out", ".", "directory to write PNG files to")
+	flag.StringVar(&shots, "shot", "", "comma-separated frame numbers to save (e.g. 60,300)")
+	flag.IntVar(&opts.every, "every", 0, "also save every N frames")
+	flag.IntVar(&opts.untilStill, "until-still", 0, "stop when the screen has not changed for N frames")
+	flag.StringVar(&opts.savPath, "sav", "", "battery save (.sav) file to load")
+	flag.StringVar(&opts.dmgBoot, "boot-dmg", "", "DMG boot ROM file (used for DMG cartridges)")
+	flag.StringVar(&opts.cgbBoot, "boot-cgb", "", "CGB boot ROM file (used for CGB cartridges)")
+	flag.StringVar(&opts.mapper, "mapper", "", "override the mapper in the cartridge header (e.g. mbc1, mmm01, wisdomtree, m161, sachen)")
+	flag.StringVar(&opts.renderer, "renderer", "", "PPU renderer: \"scanline\" (default) or \"fifo\" (mid-scanline effects)")
+	flag.BoolVar(&opts.isPPUMemoryFree, "free-ppu-memory", false, "allow VRAM/OAM accesses while the PPU uses them (for debugging homebrew)")
+	flag.StringVar(&opts.camera, "camera", "", "image for the Game Boy Camera: PNG file, directory of PNG files, or \"test\"")
+	flag.StringVar(&opts.moviePath, "movie", "", "play the input of a movie file recorded by gomeboy -record")
+	flag.BoolVar(&opts.isSerial, "serial", false, "print the bytes sent over the serial port to stdout")
+	flag.BoolVar(&opts.isRumble, "rumble", false, "print the rumble motor changes of MBC5+RBL cartridges")
+	var tilt string
+	flag.StringVar(&tilt, "tilt", "", "fixed tilt \"x,y\" in G for MBC7 cartridges (e.g. 0.5,0)")
+	flag.BoolVar(&opts.isTestROM, "test-rom", false, "exit with 0 (passed), 1 (failed) or 3 (timed out) by the test ROM result")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gomeboy-headless [flags] <romfile>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts.shots = map[int]bool{}
+	for _, s := range strings.Split(shots, ",") {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			log.Fatalf("invalid frame number %q", s)
+		}
+		opts.shots[n] = true
+	}
+	if tilt != "" {
+		if _, err := fmt.Sscanf(tilt, "%g,%g", &opts.tiltX, &opts.tiltY); err != nil {
+			log.Fatalf("invalid tilt %q", tilt)
+		}
+	}
+	return opts, flag.Arg(0)
+}
+
+// The writeFrame saves the screen as "<out>/<rom name>_<frame>.png".
+func writeFrame(screen *image.RGBA, outDir, base string, frame int) {
+	path := filepath.Join(outDir, fmt.Sprintf("%s_%05d.png", base, frame))
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, screen); err != nil {
+		log.Fatal(err)
+	}
+}
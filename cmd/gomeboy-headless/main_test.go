@@ -0,0 +1,121 @@
+package main
+
+import (
+	"gomeboy/internal/emulator"
+	"gomeboy/internal/testrom"
+	"image"
+	"slices"
+	"testing"
+)
+
+// The newTestROM returns a ROM that runs the program from 0x0150.
+func newTestROM(program ...byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x00, 0xC3, 0x50, 0x01}) // NOP; JP $0150
+	copy(rom[0x150:], program)
+	return rom
+}
+
+var (
+	stillProgram = []byte{0x18, 0xFE} // JR -2
+
+	// Increments BGP once a frame (at LY = 144), so every frame differs from the previous one.
+	changingProgram = []byte{
+		0xF0, 0x44, 0xFE, 0x90, 0x20, 0xFA, // Wait for LY = 144
+		0xF0, 0x47, 0x3C, 0xE0, 0x47, // INC BGP
+		0xF0, 0x44, 0xFE, 0x90, 0x28, 0xFA, // Wait for LY != 144
+		0x18, 0xED, // JR to the top
+	}
+
+	// LD B, 3; LD C, 5; LD D, 8; LD E, 13; LD H, 21; LD L, 34; LD B, B (Mooneye passed)
+	passedProgram = []byte{0x06, 3, 0x0E, 5, 0x16, 8, 0x1E, 13, 0x26, 21, 0x2E, 34, 0x40, 0x18, 0xFE}
+	// LD B, $42; ...; LD B, B (Mooneye failed)
+	failedProgram = []byte{0x06, 0x42, 0x0E, 0x42, 0x16, 0x42, 0x1E, 0x42, 0x26, 0x42, 0x2E, 0x42, 0x40, 0x18, 0xFE}
+)
+
+func TestRunFrames(t *testing.T) {
+	tests := []struct {
+		name      string
+		program   []byte
+		opts      options
+		isTestROM bool
+		want      testrom.Result
+		wantFrame int   // Number of the last frame (= the name of the last PNG)
+		wantSaved []int // Frames passed to save
+	}{
+		{
+			name: "all frames", program: stillProgram,
+			opts: options{frames: 10, shots: map[int]bool{2: true, 11: true}, every: 4},
+			want: testrom.TimedOut, wantFrame: 10, wantSaved: []int{2, 4, 8},
+		},
+		{
+			name: "until still", program: stillProgram,
+			opts: options{frames: 100, untilStill: 3},
+			want: testrom.TimedOut, wantFrame: 4,
+		},
+		{
+			name: "never still", program: changingProgram,
+			opts: options{frames: 20, untilStill: 3, every: 10},
+			want: testrom.TimedOut, wantFrame: 20, wantSaved: []int{10, 20},
+		},
+		{
+			name: "test ROM passed", program: passedProgram, isTestROM: true,
+			opts: options{frames: 100, every: 1},
+			want: testrom.Passed, wantFrame: 1,
+		},
+		{
+			name: "test ROM failed", program: failedProgram, isTestROM: true,
+			opts: options{frames: 100},
+			want: testrom.Failed, wantFrame: 1,
+		},
+		{
+			name: "test ROM timed out", program: stillProgram, isTestROM: true,
+			opts: options{frames: 30},
+			want: testrom.TimedOut, wantFrame: 30,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emu, err := emulator.NewEmulator(newTestROM(tt.program...), nil, emulator.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var checker *testrom.Checker
+			if tt.isTestROM {
+				checker = testrom.NewChecker(emu, nil)
+			}
+			var saved []int
+			result, frame, err := runFrames(emu, checker, &tt.opts, func(screen *image.RGBA, frame int) {
+				saved = append(saved, frame)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %s, want %s", result, tt.want)
+			}
+			if frame != tt.wantFrame {
+				t.Errorf("last frame = %d, want %d", frame, tt.wantFrame)
+			}
+			if !slices.Equal(saved, tt.wantSaved) {
+				t.Errorf("saved frames = %v, want %v", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		result testrom.Result
+		want   int
+	}{
+		{testrom.Passed, 0},
+		{testrom.Failed, 1},
+		{testrom.TimedOut, 3},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.result); got != tt.want {
+			t.Errorf("exitCode(%s) = %d, want %d", tt.result, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"gomeboy/internal/cartridge"
+	"os"
+)
+
+// The runInfo prints the cartridge header of each ROM. ("gomeboy info <romfile>...")
+// It returns false if a ROM cannot be read or has a wrong checksum.
+func runInfo(paths []string) bool {
+	isOK := true
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Println()
+		}
+		rom, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			isOK = false
+			continue
+		}
+		h, err := cartridge.Parse(rom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			isOK = false
+			continue
+		}
+		printHeader(path, rom, h)
+		isOK = isOK && h.IsHeaderChecksumValid && h.IsGlobalChecksumValid
+	}
+	return isOK
+}
+
+func printHeader(path string, rom []byte, h *cartridge.Header) {
+	cgb := "No"
+	switch {
+	case h.IsCGBOnly():
+		cgb = "CGB only"
+	case h.IsCGB():
+		cgb = "Enhanced"
+	}
+
+	fmt.Printf("File:              %s (%d bytes)\n", path, len(rom))
+	fmt.Printf("Title:             %s\n", h.Title)
+	if h.ManufacturerCode != "" {
+		fmt.Printf("Manufacturer code: %s\n", h.ManufacturerCode)
+	}
+	fmt.Printf("CGB:               %s (0x%02X)\n", cgb, h.CGBFlag)
+	fmt.Printf("SGB:               %t (0x%02X)\n", h.IsSGB(), h.SGBFlag)
+	fmt.Printf("Licensee:          %s\n", h.LicenseeCode())
+	fmt.Printf("Cartridge type:    %s (0x%02X)\n", h.CartTypeName(), h.CartType)
+	fmt.Printf("ROM size:          %s (0x%02X)\n", formatBanks(h.ROMBanks(), 16), h.ROMSizeCode)
+	fmt.Printf("RAM size:          %s (0x%02X)\n", formatBanks(h.RAMBanks(), 8), h.RAMSizeCode)
+	fmt.Printf("Destination:       %s (0x%02X)\n", h.DestinationName(), h.Destination)
+	fmt.Printf("Version:           %d\n", h.Version)
+	fmt.Printf("Header checksum:   0x%02X %s\n", h.HeaderChecksum, formatValid(h.IsHeaderChecksumValid))
+	fmt.Printf("Global checksum:   0x%04X %s\n", h.GlobalChecksum, formatValid(h.IsGlobalChecksumValid))
+}
+
+func formatBanks(banks, kib int) string {
+	if banks == -1 {
+		return "Unknown"
+	}
+	return fmt.Sprintf("%d KiB (%d banks)", banks*kib, banks)
+}
+
+func formatValid(isValid bool) string {
+	if isValid {
+		return "OK"
+	}
+	return "NG"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"gomeboy/internal/joypad"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// The ebitenInput reads the keyboard and the gamepad
+// and passes them to the emulator as joypad buttons.
+type ebitenInput struct {
+	isGamepadEnabled bool   // From config.toml
+	gamepadBind      [8]int // From config.toml
+	pixelScale       int    // For the mouse tilt
+}
+
+// Same order as joypad.ButtonXX
+var keyBind = [8]ebiten.Key{
+	ebiten.KeyZ,         // A
+	ebiten.KeyX,         // B
+	ebiten.KeyShiftLeft, // SELECT
+	ebiten.KeyEnter,     // START
+	ebiten.KeyRight,     // RIGHT
+	ebiten.KeyLeft,      // LEFT
+	ebiten.KeyUp,        // UP
+	ebiten.KeyDown,      // DOWN
+}
+
+// (Pressed=1, Released=0)
+func (in *ebitenInput) Buttons() byte {
+	mask := byte(0)
+	for i, k := range keyBind {
+		if ebiten.IsKeyPressed(k) {
+			mask |= joypad.ButtonA << i
+		}
+	}
+	if in.isGamepadEnabled {
+		id := ebiten.GamepadID(0)
+		for i, v := range in.gamepadBind {
+			if ebiten.IsGamepadButtonPressed(id, ebiten.GamepadButton(v)) {
+				mask |= joypad.ButtonA << i
+			}
+		}
+	}
+	return mask
+}
+
+// The Tilt tilts the cartridge (MBC7) with the left stick of the gamepad,
+// or by dragging the mouse away from the center of the screen.
+func (in *ebitenInput) Tilt() (x, y float64) {
+	if in.isGamepadEnabled {
+		id := ebiten.GamepadID(0)
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			x = ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+			y = ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickVertical)
+			if x != 0 || y != 0 {
+				return x, y
+			}
+		}
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		// -1.0 ~ +1.0 from the center of the Game Boy screen
+		mx, my := ebiten.CursorPosition()
+		w, h := float64(160*in.pixelScale), float64(144*in.pixelScale)
+		x = max(-1, min(1, (float64(mx)-w/2)/(w/2)))
+		y = max(-1, min(1, (float64(my)-h/2)/(h/2)))
+	}
+	return x, y
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"gomeboy/config"
 	"gomeboy/internal/apu"
+	"gomeboy/internal/camera"
 	"gomeboy/internal/emulator"
+	"gomeboy/internal/movie"
+	"gomeboy/internal/rewind"
+	"gomeboy/internal/savefile"
 	"image"
 	"image/color"
 	"image/draw"
@@ -18,6 +23,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/audio"
 	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
@@ -33,9 +39,20 @@ type Game struct {
 	pixelScale           int
 	isDebugScreenEnabled bool
 	debugLog             []string
+	statePath            string
+	saveFlusher          *savefile.Flusher // Writes the .sav file
+	rewindFrames         int               // Frames since the rewind key was pressed
+	movieToPlay          *movie.Movie      // Set by -play
+	recordPath           string            // Set by -record
+	isRecordFromState    bool              // Set by -from-state
+	recordedMovie        *movie.Movie      // Written to recordPath on exit
+
+	// Rumble motor of the cartridge
+	isMotorOn         bool
+	wasMotorOnInFrame bool
 }
 
-func newGame(g *Game, rom, sav []byte) *Game {
+func newGame(g *Game, rom, sav []byte, savPath string, opts emulator.Options) *Game {
 	screenFont, _ = text.NewGoTextFaceSource(bytes.NewReader(fonts.PressStart2P_ttf))
 
 	debuggerWidth := 0
@@ -45,10 +62,40 @@ func newGame(g *Game, rom, sav []byte) *Game {
 	g.imageRGBA = image.NewRGBA(image.Rect(0, 0, 160+debuggerWidth, 144))
 	g.ebitenImage = ebiten.NewImage(160+debuggerWidth, 144)
 
-	g.emu = emulator.NewEmulator(rom, sav)
+	var err error
+	if g.emu, err = emulator.NewEmulator(rom, sav, opts); err != nil {
+		log.Fatal(err)
+	}
+
+	g.saveFlusher = savefile.NewFlusher(savPath, g.emu.CPU.Bus.Memory)
+	if g.cfg.Rewind.IsEnabled {
+		g.emu.EnableRewind(g.cfg.Rewind.MemoryMB<<20, rewind.DefaultInterval)
+	}
+	if g.movieToPlay != nil || g.recordPath != "" {
+		g.saveFlusher.Path = "" // The game played in a movie is not the player's save.
+		g.startMovie()
+	}
+
+	g.emu.Input = &ebitenInput{
+		isGamepadEnabled: g.cfg.Gamepad.IsEnabled,
+		gamepadBind:      g.cfg.Gamepad.Bind,
+		pixelScale:       g.pixelScale,
+	}
+
+	if g.cfg.Camera.Source != "" {
+		src, err := camera.NewSource(g.cfg.Camera.Source)
+		if err != nil {
+			g.fatal(err)
+		}
+		g.emu.SetCameraSource(src)
+	}
 
-	g.emu.CPU.Bus.Joypad.SetIsGamepadEnabled(g.cfg.Gamepad.IsEnabled)
-	g.emu.CPU.Bus.Joypad.SetIsGamepadBind(g.cfg.Gamepad.Bind)
+	g.emu.SetRumbleHandler(func(isOn bool) {
+		g.isMotorOn = isOn
+		if isOn {
+			g.wasMotorOnInFrame = true
+		}
+	})
 
 	g.audioCtx = audio.NewContext(int(apu.SampleRate))
 	g.audioPlayer, _ = g.audioCtx.NewPlayerF32(g.emu.CPU.Bus.APU.AudioStream)
@@ -62,8 +109,21 @@ func newGame(g *Game, rom, sav []byte) *Game {
 // Game.Update() calls Emulator.RunFrame() at 60FPS.
 func (g *Game) Update() error {
 	g.setWindowTitle()
-	if !ebiten.IsFocused() || g.emu.IsPauseMode {
+	g.updateSaveStateKeys()
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) { // for debug
+		g.emu.DumpTrace()
+		return ebiten.Termination
+	}
+	g.updateDebugKeys()
+	if ebiten.IsFocused() && !g.emu.IsPaused && ebiten.IsKeyPressed(ebiten.KeyBackspace) {
+		g.audioPlayer.Pause()
+		g.updateRewind()
+		return nil
+	}
+	g.rewindFrames = 0
+	if !ebiten.IsFocused() || g.emu.IsPaused {
 		g.audioPlayer.Pause()
+		g.flushSaveFile()
 	} else {
 		g.audioPlayer.Play()
 	}
@@ -71,10 +131,86 @@ func (g *Game) Update() error {
 		if g.emu.RunFrame() == -1 {
 			return ebiten.Termination
 		}
+		g.updateRumble()
+		if err := g.saveFlusher.Update(); err != nil {
+			log.Println(err)
+		}
 	}
 	return nil
 }
 
+// While Backspace is held, the game goes back in real time (a snapshot every interval frames).
+func (g *Game) updateRewind() {
+	if g.rewindFrames%rewind.DefaultInterval == 0 {
+		if g.emu.RewindFrame() {
+			g.saveFlusher.MarkDirty() // The cartridge RAM is also restored.
+		}
+	}
+	g.rewindFrames++
+}
+
+// The startMovie starts playing -play, or recording to -record
+// (from power-on, or from the .state file with -from-state).
+func (g *Game) startMovie() {
+	if g.movieToPlay != nil {
+		if err := g.emu.PlayMovie(g.movieToPlay); err != nil {
+			g.fatal(err)
+		}
+		return
+	}
+	if g.isRecordFromState {
+		f, err := os.Open(g.statePath)
+		if err != nil {
+			g.fatal(err)
+		}
+		defer f.Close()
+		if err := g.emu.LoadState(f); err != nil {
+			g.fatal(err)
+		}
+	}
+	var err error
+	if g.recordedMovie, err = g.emu.StartRecording(); err != nil {
+		g.fatal(err)
+	}
+}
+
+// The saveMovie writes the recorded movie (even if the recording was stopped by loading a state).
+func (g *Game) saveMovie() {
+	if g.recordedMovie == nil {
+		return
+	}
+	if err := g.recordedMovie.Save(g.recordPath); err != nil {
+		log.Println(err)
+	}
+}
+
+// The flushSaveFile writes the .sav file if the game has written to the cartridge RAM.
+func (g *Game) flushSaveFile() {
+	if err := g.saveFlusher.Flush(); err != nil {
+		log.Println(err)
+	}
+}
+
+// The fatal exits after writing the .sav file, as log.Fatal skips the flush at the end of main.
+func (g *Game) fatal(err error) {
+	if g.saveFlusher != nil {
+		g.flushSaveFile()
+	}
+	log.Fatal(err)
+}
+
+// While the motor is on (even for a moment in the frame), the gamepad vibrates until the next frame.
+func (g *Game) updateRumble() {
+	if g.cfg.Gamepad.IsEnabled && (g.isMotorOn || g.wasMotorOnInFrame) {
+		ebiten.VibrateGamepad(ebiten.GamepadID(0), &ebiten.VibrateGamepadOptions{
+			Duration:        2 * time.Second / 60,
+			StrongMagnitude: 1.0,
+			WeakMagnitude:   1.0,
+		})
+	}
+	g.wasMotorOnInFrame = false
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	gameScreen := g.emu.CPU.Bus.PPU.GetGameScreen()
 	draw.Draw(g.imageRGBA, image.Rect(0, 0, 160, 144), gameScreen, gameScreen.Rect.Min, draw.Src)
@@ -105,7 +241,27 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 }
 
 func main() {
+	if len(os.Args) >= 2 && os.Args[1] == "info" {
+		if len(os.Args) < 3 {
+			fmt.Println("usage: gomeboy info <romfile>...")
+			os.Exit(2)
+		}
+		if !runInfo(os.Args[2:]) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	g := &Game{}
+	flag.StringVar(&g.recordPath, "record", "", "record the input to a movie file (from power-on, without the .sav file)")
+	flag.BoolVar(&g.isRecordFromState, "from-state", false, "with -record, start the movie from the .state file (F5) instead of power-on")
+	playPath := flag.String("play", "", "play a movie file recorded with -record")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gomeboy [flags] <romfile>")
+		fmt.Fprintln(flag.CommandLine.Output(), "       gomeboy info <romfile>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
 	var err error
 	if g.cfg, err = config.Load("config.toml"); err != nil {
@@ -116,18 +272,40 @@ func main() {
 	g.pixelScale = min(g.pixelScale, 4)
 	g.isDebugScreenEnabled = g.cfg.Video.IsShowDebug
 
-	if len(os.Args) < 2 {
-		fmt.Println("usage: gomeboy <romfile>")
+	if flag.NArg() < 1 {
+		flag.Usage()
 		return
 	}
-	romPath := os.Args[1]
+	romPath := flag.Arg(0)
 	rom, err := os.ReadFile(romPath)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *playPath != "" {
+		if g.movieToPlay, err = movie.Load(*playPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	savPath := getSavePathFromROM(romPath, g.cfg.Save.Dir)
+	var sav []byte
+	isFromPowerOn := (g.recordPath != "" && !g.isRecordFromState) ||
+		(g.movieToPlay != nil && g.movieToPlay.IsFromPowerOn())
+	if !isFromPowerOn { // A movie from power-on does not contain the .sav file.
+		sav, _ = os.ReadFile(savPath)
+	}
+	g.statePath = getStatePathFromROM(romPath)
 
-	savPath := getSavePathFromROM(romPath)
-	sav, _ := os.ReadFile(savPath)
+	var opts emulator.Options
+	if opts.DMGBootROM, err = readBootROM(g.cfg.BootROM.DMG); err != nil {
+		log.Fatal(err)
+	}
+	if opts.CGBBootROM, err = readBootROM(g.cfg.BootROM.CGB); err != nil {
+		log.Fatal(err)
+	}
+	opts.Mapper = g.cfg.Mappers[filepath.Base(romPath)]
+	opts.Renderer = g.cfg.Video.Renderer
+	opts.IsPPUMemoryFree = g.cfg.Debug.IsPPUMemoryFree
 
 	windowHeight := 144 * g.pixelScale
 	windowWidth := 160 * g.pixelScale
@@ -136,13 +314,12 @@ func main() {
 	}
 	ebiten.SetWindowSize(windowWidth, windowHeight)
 
-	err = ebiten.RunGame(newGame(g, rom, sav))
+	err = ebiten.RunGame(newGame(g, rom, sav, savPath, opts))
+	// When the emulator is closed, save ERAM(save) data.
+	g.flushSaveFile()
+	g.saveMovie()
 	if err != nil && err != ebiten.Termination {
 		panic(err)
-	} else {
-		// When the emulator is closed, save ERAM(save) data.
-		savData := g.emu.CPU.Bus.Memory.GetSaveData()
-		os.WriteFile(savPath, savData, 0644)
 	}
 }
 
@@ -156,17 +333,80 @@ func (g *Game) drawText(dst *ebiten.Image, msg string, x, y, size int, cr color.
 	}, op)
 }
 
-func getSavePathFromROM(romPath string) string {
+// The .sav file is next to the ROM, or in saveDir if set.
+func getSavePathFromROM(romPath, saveDir string) string {
 	ext := filepath.Ext(romPath)
 	base := romPath[:len(romPath)-len(ext)]
+	if saveDir != "" {
+		base = filepath.Join(saveDir, filepath.Base(base))
+	}
 	return base + ".sav"
 }
 
+// KeyP: Toggle Run/Pause Mode
+// KeyS: Run a single step
+func (g *Game) updateDebugKeys() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		g.emu.IsPaused = !g.emu.IsPaused
+	}
+	if g.emu.IsPaused && inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		g.emu.StepInstruction()
+	}
+}
+
+// The readBootROM returns nil if no boot ROM is configured.
+func readBootROM(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+func getStatePathFromROM(romPath string) string {
+	ext := filepath.Ext(romPath)
+	base := romPath[:len(romPath)-len(ext)]
+	return base + ".state"
+}
+
+// F5: Save state
+// F8: Load state
+func (g *Game) updateSaveStateKeys() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyF5):
+		var buf bytes.Buffer
+		if err := g.emu.SaveState(&buf); err != nil {
+			log.Println(err)
+			return
+		}
+		if err := os.WriteFile(g.statePath, buf.Bytes(), 0644); err != nil {
+			log.Println(err)
+		}
+	case inpututil.IsKeyJustPressed(ebiten.KeyF8):
+		f, err := os.Open(g.statePath)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer f.Close()
+		if err := g.emu.LoadState(f); err != nil {
+			log.Println(err)
+			return
+		}
+		g.saveFlusher.MarkDirty() // The cartridge RAM is also restored.
+	}
+}
+
 func (g *Game) setWindowTitle() {
 	emuState := ""
 	if g.emu.IsPaused {
 		emuState = "(paused)"
 	}
+	switch {
+	case g.emu.IsRecordingMovie():
+		emuState += "(recording)"
+	case g.emu.IsPlayingMovie():
+		emuState += "(playing)"
+	}
 	if len(g.emu.ROMTitle) > 0 {
 		ebiten.SetWindowTitle(emuState + "GOmeBoy - " + g.emu.ROMTitle)
 	} else {
@@ -13,14 +13,46 @@ func Load(path string) (*Config, error) {
 type Config struct {
 	Video   VideoConfig   `toml:"video"`
 	Gamepad GamepadConfig `toml:"gamepad"`
+	BootROM BootROMConfig `toml:"bootrom"`
+	Camera  CameraConfig  `toml:"camera"`
+	Save    SaveConfig    `toml:"save"`
+	Rewind  RewindConfig  `toml:"rewind"`
+	Debug   DebugConfig   `toml:"debug"`
+
+	// Mapper overrides for cartridges with a wrong header
+	// (Key = ROM file name, Value = mapper name, e.g. "wisdomtree")
+	Mappers map[string]string `toml:"mappers"`
 }
 
 type VideoConfig struct {
-	Scale       int  `toml:"scale"`
-	IsShowDebug bool `toml:"show_debug"`
+	Scale       int    `toml:"scale"`
+	IsShowDebug bool   `toml:"show_debug"`
+	Renderer    string `toml:"renderer"` // "scanline" or "fifo" (empty = scanline)
 }
 
 type GamepadConfig struct {
 	IsEnabled bool   `toml:"enabled"`
 	Bind      [8]int `toml:"bind"`
 }
+
+type BootROMConfig struct {
+	DMG string `toml:"dmg"` // File path (empty = no boot ROM)
+	CGB string `toml:"cgb"` // File path (empty = no boot ROM)
+}
+
+type CameraConfig struct {
+	Source string `toml:"source"` // PNG file, directory of PNG files, or "test" (empty = test pattern)
+}
+
+type SaveConfig struct {
+	Dir string `toml:"dir"` // Directory for .sav files (empty = next to the ROM)
+}
+
+type RewindConfig struct {
+	IsEnabled bool `toml:"enabled"`
+	MemoryMB  int  `toml:"memory_mb"` // Memory for the snapshots
+}
+
+type DebugConfig struct {
+	IsPPUMemoryFree bool `toml:"free_ppu_memory"` // Access VRAM/OAM in any PPU mode
+}
@@ -16,6 +16,7 @@ var b [8]byte // Declared here for optimization.
 
 type APU struct {
 	AudioStream *AudioStream
+	IsMuted     bool // Samples are generated, but not written to the AudioStream. (e.g. while rewinding)
 
 	cycles float64
 
@@ -77,41 +78,29 @@ type APU struct {
 	ch4SampleCountForLFSR        float64
 	ch4LengthTimer               int
 	lfsr                         uint16
-
-	distanceThreshold    int
-	samplingAcceleration float64
 }
 
 func NewAPU() *APU {
 	bufferMilliSecond := float64(120)
 	bufferSize := int(SampleRate * 8 * bufferMilliSecond / 1000)
 	a := &APU{
-		AudioStream:          NewAudioStream(bufferSize),
-		lfsr:                 0x7FFF,
-		samplingAcceleration: 1.0,
+		AudioStream: NewAudioStream(bufferSize),
+		lfsr:        0x7FFF,
 	}
-
-	a.distanceThreshold = int(float64(len(a.AudioStream.buffer)) * 0.40)
 	return a
 }
 
-// The Step adjusts the sampling interval depending on the current buffer size.
+// The Step always generates a sample every CyclesPerSample cycles,
+// so the emulated state never depends on how fast the host plays the audio.
+// (The AudioStream absorbs the difference. See AudioStream.Read)
 func (a *APU) Step(cpuCycles int) {
-	targetAcceleration := 1.0
-	w := a.AudioStream.w
-	r := a.AudioStream.r
-	t := a.distanceThreshold
-	if w-r < -t {
-		targetAcceleration = 1.5
-	} else if w-r > t {
-		targetAcceleration = 0.5
-	}
-	a.samplingAcceleration += (targetAcceleration - a.samplingAcceleration) * 0.01
-	a.cycles += float64(cpuCycles) * a.samplingAcceleration
+	a.cycles += float64(cpuCycles)
 	for a.cycles >= CyclesPerSample {
 		a.cycles -= CyclesPerSample
 		b := a.generateSample()
-		a.AudioStream.write(b)
+		if !a.IsMuted {
+			a.AudioStream.write(b)
+		}
 	}
 }
 
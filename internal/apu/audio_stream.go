@@ -1,24 +1,47 @@
 package apu
 
+const bytesPerFrame = 8 // float32 (L) + float32 (R)
+
 type AudioStream struct {
 	buffer []byte
 	r      int // read position
 	w      int // write position
+
+	// The host plays a little faster or slower than the emulator generates samples.
+	// The reader skips or repeats samples to keep the buffer about half full.
+	threshold int     // Distance from the half at which the rate starts to change
+	rate      float64 // Frames read per frame played
+	frac      float64 // Fractional part of the read position (in frames)
 }
 
 func NewAudioStream(size int) *AudioStream {
+	size -= size % bytesPerFrame
 	return &AudioStream{
-		buffer: make([]byte, size),
+		buffer:    make([]byte, size),
+		threshold: size / 10,
+		rate:      1.0,
 	}
 }
 
 // The Read is the Implementation of io.Reader.Read().
 func (as *AudioStream) Read(p []byte) (int, error) {
-	n := 0
-	for i := range p {
-		p[i] = as.buffer[as.r]
-		as.r = (as.r + 1) % len(as.buffer)
-		n++
+	size := len(as.buffer)
+	distance := (as.w - as.r + size) % size
+	targetRate := 1.0
+	if distance < size/2-as.threshold {
+		targetRate = 0.5
+	} else if distance > size/2+as.threshold {
+		targetRate = 1.5
+	}
+	as.rate += (targetRate - as.rate) * 0.1
+
+	n := len(p) - len(p)%bytesPerFrame
+	for i := 0; i < n; i += bytesPerFrame {
+		copy(p[i:i+bytesPerFrame], as.buffer[as.r:as.r+bytesPerFrame])
+		as.frac += as.rate
+		step := int(as.frac)
+		as.frac -= float64(step)
+		as.r = (as.r + step*bytesPerFrame) % size
 	}
 	return n, nil
 }
@@ -0,0 +1,113 @@
+package apu
+
+import "gomeboy/internal/savestate"
+
+// The AudioStream belongs to the host side, so it is not part of the saved state.
+func (a *APU) SaveState(e *savestate.Encoder) {
+	e.Write(a.cycles)
+	e.Write([3]byte{a.nr52, a.nr51, a.nr50})
+
+	// Sound channel 1
+	e.Write([5]byte{a.nr10, a.nr11, a.nr12, a.nr13, a.nr14})
+	e.Write(a.ch1Vol)
+	e.Write(a.ch1SampleCountForLengthTimer)
+	e.Write(a.ch1SampleCountForEnvelope)
+	e.WriteInt(a.ch1LengthTimer)
+	e.Write(a.ch1Phase)
+	e.Write(a.ch1SampleCountForSweep)
+	e.Write(a.ch1Period)
+
+	// Sound channel 2
+	e.Write([4]byte{a.nr21, a.nr22, a.nr23, a.nr24})
+	e.Write(a.ch2Vol)
+	e.Write(a.ch2SampleCountForLengthTimer)
+	e.Write(a.ch2SampleCountForEnvelope)
+	e.WriteInt(a.ch2LengthTimer)
+	e.Write(a.ch2Phase)
+	e.Write(a.ch2Period)
+
+	// Sound channel 3
+	e.Write(&a.waveRAM)
+	e.Write([5]byte{a.nr30, a.nr31, a.nr32, a.nr33, a.nr34})
+	e.Write(a.ch3SampleCountForLengthTimer)
+	e.WriteInt(a.ch3LengthTimer)
+	e.Write(a.ch3Phase)
+	e.WriteInt(a.indexWaveRAM)
+
+	// Sound channel 4
+	e.Write([4]byte{a.nr41, a.nr42, a.nr43, a.nr44})
+	e.Write(a.ch4Vol)
+	e.Write(a.ch4SampleCountForLengthTimer)
+	e.Write(a.ch4SampleCountForEnvelope)
+	e.Write(a.ch4SampleCountForLFSR)
+	e.WriteInt(a.ch4LengthTimer)
+	e.Write(a.lfsr)
+}
+
+func (a *APU) LoadState(d *savestate.Decoder) {
+	d.Read(&a.cycles)
+	var global [3]byte
+	d.Read(&global)
+	a.nr52, a.nr51, a.nr50 = global[0], global[1], global[2]
+
+	// Sound channel 1
+	var ch1 [5]byte
+	d.Read(&ch1)
+	a.nr10, a.nr11, a.nr12, a.nr13, a.nr14 = ch1[0], ch1[1], ch1[2], ch1[3], ch1[4]
+	d.Read(&a.ch1Vol)
+	d.Read(&a.ch1SampleCountForLengthTimer)
+	d.Read(&a.ch1SampleCountForEnvelope)
+	d.ReadInt(&a.ch1LengthTimer)
+	d.Read(&a.ch1Phase)
+	d.Read(&a.ch1SampleCountForSweep)
+	d.Read(&a.ch1Period)
+
+	// Sound channel 2
+	var ch2 [4]byte
+	d.Read(&ch2)
+	a.nr21, a.nr22, a.nr23, a.nr24 = ch2[0], ch2[1], ch2[2], ch2[3]
+	d.Read(&a.ch2Vol)
+	d.Read(&a.ch2SampleCountForLengthTimer)
+	d.Read(&a.ch2SampleCountForEnvelope)
+	d.ReadInt(&a.ch2LengthTimer)
+	d.Read(&a.ch2Phase)
+	d.Read(&a.ch2Period)
+
+	// Sound channel 3
+	d.Read(&a.waveRAM)
+	var ch3 [5]byte
+	d.Read(&ch3)
+	a.nr30, a.nr31, a.nr32, a.nr33, a.nr34 = ch3[0], ch3[1], ch3[2], ch3[3], ch3[4]
+	d.Read(&a.ch3SampleCountForLengthTimer)
+	d.ReadInt(&a.ch3LengthTimer)
+	d.Read(&a.ch3Phase)
+	d.ReadInt(&a.indexWaveRAM)
+
+	// Sound channel 4
+	var ch4 [4]byte
+	d.Read(&ch4)
+	a.nr41, a.nr42, a.nr43, a.nr44 = ch4[0], ch4[1], ch4[2], ch4[3]
+	d.Read(&a.ch4Vol)
+	d.Read(&a.ch4SampleCountForLengthTimer)
+	d.Read(&a.ch4SampleCountForEnvelope)
+	d.Read(&a.ch4SampleCountForLFSR)
+	d.ReadInt(&a.ch4LengthTimer)
+	d.Read(&a.lfsr)
+	a.checkState(d)
+}
+
+// The counters are consumed by loops (e.g. "for counter >= period"),
+// so a NaN or a huge value in a broken state would hang them.
+func (a *APU) checkState(d *savestate.Decoder) {
+	isCounter := func(v float64) bool { return v >= 0 && v < 1<<16 }
+	isPhase := func(v float64) bool { return v >= 0 && v < 1 }
+	d.Check(isCounter(a.cycles), "APU cycles")
+	d.Check(isCounter(a.ch1SampleCountForLengthTimer) && isCounter(a.ch1SampleCountForEnvelope) &&
+		isCounter(a.ch1SampleCountForSweep) && isPhase(a.ch1Phase) && a.ch1Period <= 0x7FF, "channel 1")
+	d.Check(isCounter(a.ch2SampleCountForLengthTimer) && isCounter(a.ch2SampleCountForEnvelope) &&
+		isPhase(a.ch2Phase) && a.ch2Period <= 0x7FF, "channel 2")
+	d.Check(isCounter(a.ch3SampleCountForLengthTimer) && isPhase(a.ch3Phase) &&
+		a.indexWaveRAM >= 0 && a.indexWaveRAM < 32, "channel 3")
+	d.Check(isCounter(a.ch4SampleCountForLengthTimer) && isCounter(a.ch4SampleCountForEnvelope) &&
+		isCounter(a.ch4SampleCountForLFSR), "channel 4")
+}
@@ -5,6 +5,7 @@ import (
 	"gomeboy/internal/joypad"
 	"gomeboy/internal/memory"
 	"gomeboy/internal/ppu"
+	"gomeboy/internal/serial"
 	"gomeboy/internal/timer"
 )
 
@@ -14,11 +15,19 @@ type Bus struct {
 	Joypad *joypad.Joypad
 	Memory *memory.Memory
 	APU    *apu.APU
+	Serial *serial.Serial
 
 	// DMA Transfer
 	IsDMATransferInProgress bool
 	DMATransferIndex        int // 0 ~ 159
 
+	// General-purpose VRAM DMA (CGB mode only, the CPU is stopped until it is done)
+	IsGDMATransferInProgress bool
+
+	// If true, the CPU can access VRAM/OAM in any PPU mode and during OAM DMA.
+	// (Real hardware reads 0xFF and ignores writes. For debugging homebrew)
+	IsPPUMemoryFree bool
+
 	// CGB double speed mode (KEY1/SPD)
 	IsWSpeed      bool
 	IsSwitchArmed bool
@@ -91,44 +100,52 @@ const (
 	IE uint16 = 0xFFFF
 )
 
+// The NewBus returns the Bus in the power-on state (all the I/O registers are cleared).
+// Without a boot ROM, SetPostBootState must be called before running.
 func NewBus(m *memory.Memory) *Bus {
-	bus := &Bus{
+	return &Bus{
 		PPU:   ppu.NewPPU(),
 		APU:   apu.NewAPU(),
 		Timer: timer.NewTimer(),
 
 		Joypad: joypad.NewJoypad(),
+		Serial: serial.NewSerial(),
 		Memory: m,
 	}
+}
+
+// The SetPostBootState sets the I/O registers to the values the boot ROM leaves.
+func (b *Bus) SetPostBootState() {
 	// Serial
-	bus.Write(SB, 0x00)
-	bus.Write(SC, 0x7E)
+	b.Write(SB, 0x00)
+	b.Write(SC, 0x7E)
 
 	// Interrupt
-	bus.Write(IF, 0x01)
-	bus.Write(IE, 0x00)
+	b.Write(IF, 0x01)
+	b.Write(IE, 0x00)
 
 	// Sound
-	bus.Write(NR10, 0x80)
-	bus.Write(NR11, 0xBF)
-	bus.Write(NR12, 0xF3)
-	bus.Write(NR14, 0xBF)
-	bus.Write(NR21, 0x3F)
-	bus.Write(NR22, 0x00)
-	bus.Write(NR24, 0xBF)
-	bus.Write(NR30, 0x7F)
-	bus.Write(NR31, 0xFF)
-	bus.Write(NR32, 0x9F)
-	bus.Write(NR34, 0xBF)
-	bus.Write(NR41, 0xFF)
-	bus.Write(NR42, 0x00)
-	bus.Write(NR43, 0x00)
-	bus.Write(NR44, 0xBF)
-	bus.Write(NR50, 0x77)
-	bus.Write(NR51, 0xF3)
-	bus.Write(NR52, 0xF1)
-
-	return bus
+	b.Write(NR10, 0x80)
+	b.Write(NR11, 0xBF)
+	b.Write(NR12, 0xF3)
+	b.Write(NR14, 0xBF)
+	b.Write(NR21, 0x3F)
+	b.Write(NR22, 0x00)
+	b.Write(NR24, 0xBF)
+	b.Write(NR30, 0x7F)
+	b.Write(NR31, 0xFF)
+	b.Write(NR32, 0x9F)
+	b.Write(NR34, 0xBF)
+	b.Write(NR41, 0xFF)
+	b.Write(NR42, 0x00)
+	b.Write(NR43, 0x00)
+	b.Write(NR44, 0xBF)
+	b.Write(NR50, 0x77)
+	b.Write(NR51, 0xF3)
+	b.Write(NR52, 0xF1)
+
+	// LCD
+	b.PPU.SetPostBootState()
 }
 
 // The Bus.Read accesses the I/O, VRAM, OAM,
@@ -137,8 +154,14 @@ func (b *Bus) Read(addr uint16) byte {
 	switch {
 	// PPU
 	case addr >= 0x8000 && addr < 0xA000:
+		if b.isVRAMLocked() {
+			return 0xFF
+		}
 		return b.PPU.ReadVRAM(addr - 0x8000)
 	case addr >= 0xFE00 && addr < 0xFEA0:
+		if b.isOAMLocked() {
+			return 0xFF
+		}
 		return b.PPU.ReadOAM(addr - 0xFE00)
 	case addr == VBK:
 		return b.PPU.GetVBK()
@@ -193,6 +216,12 @@ func (b *Bus) Read(addr uint16) byte {
 	case addr == P1_JOYP:
 		return b.Joypad.GetP1JOYP()
 
+	// Serial
+	case addr == SB:
+		return b.Serial.GetSB()
+	case addr == SC:
+		return b.Serial.GetSC()
+
 	// Interrupt
 	case addr == IF:
 		return b.Memory.Read(addr) | 0xE0
@@ -250,6 +279,8 @@ func (b *Bus) Read(addr uint16) byte {
 		return b.APU.ReadWaveRAM(addr - WaveRAMStart)
 
 	// Memory
+	case addr == BANK:
+		return 0xFF
 	case addr == SVBK_WBK:
 		return b.Memory.ReadWRAMBank()
 	default:
@@ -263,8 +294,14 @@ func (b *Bus) Write(addr uint16, val byte) {
 	switch {
 	// PPU
 	case addr >= 0x8000 && addr < 0xA000:
+		if b.isVRAMLocked() {
+			return
+		}
 		b.PPU.WriteVRAM(addr-0x8000, val)
 	case addr >= 0xFE00 && addr < 0xFEA0:
+		if b.isOAMLocked() {
+			return
+		}
 		b.PPU.WriteOAM(addr-0xFE00, val)
 	case addr == VBK:
 		b.PPU.SetVBK(val)
@@ -275,7 +312,7 @@ func (b *Bus) Write(addr uint16, val byte) {
 			b.DMATransferIndex = 0
 		}
 	case addr == LCDC:
-		b.PPU.SetLCDC(val) // TODO: LCD&PPU can be disabled only during VBlank period.
+		b.PPU.SetLCDC(val) // Games should turn the LCD off only in VBlank, but it works anytime here.
 	case addr == STAT:
 		b.PPU.SetSTAT(val)
 	case addr == SCY:
@@ -313,8 +350,12 @@ func (b *Bus) Write(addr uint16, val byte) {
 	case addr == HDMA4:
 		b.PPU.SetHDMA4(val)
 	case addr == HDMA5:
+		if !b.PPU.IsCGB {
+			return
+		}
+		isCancel := b.PPU.IsHBlankDMAActive && val&0x80 == 0
 		b.PPU.SetHDMA5(val)
-		b.vdmaTransfer()
+		b.IsGDMATransferInProgress = !isCancel && val&0x80 == 0
 	case addr == OPRI:
 		b.PPU.SetOPRI(val)
 
@@ -332,6 +373,12 @@ func (b *Bus) Write(addr uint16, val byte) {
 	case addr == P1_JOYP:
 		b.Joypad.SetP1JOYP(val)
 
+	// Serial
+	case addr == SB:
+		b.Serial.SetSB(val)
+	case addr == SC:
+		b.Serial.SetSC(val)
+
 	// Interrupt
 	case addr == IF:
 		b.Memory.Write(IF, val&0x1F)
@@ -389,6 +436,10 @@ func (b *Bus) Write(addr uint16, val byte) {
 		b.APU.WriteWaveRAM(addr-WaveRAMStart, val)
 
 	// Memory
+	case addr == BANK:
+		if val != 0 {
+			b.Memory.UnmapBootROM()
+		}
 	case addr == SVBK_WBK:
 		b.Memory.WriteWRAMBank(val)
 	default:
@@ -396,13 +447,22 @@ func (b *Bus) Write(addr uint16, val byte) {
 	}
 }
 
+func (b *Bus) isVRAMLocked() bool {
+	return !b.IsPPUMemoryFree && b.PPU.IsVRAMLocked()
+}
+
+// The CPU is stalled during the OAM DMA (see cpu.Step), so only the PPU locks OAM.
+func (b *Bus) isOAMLocked() bool {
+	return !b.IsPPUMemoryFree && b.PPU.IsOAMLocked()
+}
+
 func (b *Bus) DMATransfer() {
 	if !(b.PPU.GetDMA() <= 0xDF) || !b.IsDMATransferInProgress {
 		panic("DMA transfer error")
 	}
 	srcBase := uint16(b.PPU.GetDMA()) << 8
 	i := uint16(b.DMATransferIndex)
-	v := b.Read(srcBase + i)
+	v := b.readForDMA(srcBase + i)
 	b.PPU.WriteOAM(i, v)
 	b.DMATransferIndex++
 	if b.DMATransferIndex == 160 {
@@ -412,14 +472,39 @@ func (b *Bus) DMATransfer() {
 	}
 }
 
-func (b *Bus) vdmaTransfer() {
-	src := b.PPU.VDMASrc
-	dst := b.PPU.VDMADst
-	len := b.PPU.VDMALen
-	for i := 0; i < len; i++ {
-		b.PPU.WriteVRAM(src+uint16(i), b.Read(dst+uint16(i)))
+// The HDMATransfer copies a block (0x10 bytes) of the general-purpose DMA or the HBlank DMA,
+// and returns the CPU cycles stopped by it. (A block takes 8 M-cycles, 16 in double speed mode)
+func (b *Bus) HDMATransfer() int {
+	b.PPU.HasHDMARequested = false
+	for i := 0; i < 0x10; i++ {
+		b.PPU.WriteVRAM(b.PPU.VDMADst, b.readForDMA(b.PPU.VDMASrc))
+		b.PPU.VDMASrc++
+		b.PPU.VDMADst = (b.PPU.VDMADst + 1) & 0x1FFF
+	}
+	b.PPU.VDMALen -= 0x10
+	if b.PPU.VDMALen <= 0 {
+		b.PPU.VDMALen = 0
+		b.PPU.IsHBlankDMAActive = false
+		b.IsGDMATransferInProgress = false
+	}
+
+	cycles := 32
+	if b.IsWSpeed {
+		cycles *= 2
+	}
+	return cycles
+}
+
+// The readForDMA reads the source of the OAM DMA and the HDMA.
+// The DMA is not blocked by the lock for the CPU. (e.g. the OAM DMA from VRAM in mode 3)
+func (b *Bus) readForDMA(addr uint16) byte {
+	switch {
+	case addr >= 0x8000 && addr < 0xA000:
+		return b.PPU.ReadVRAM(addr - 0x8000)
+	case addr >= 0xFE00 && addr < 0xFEA0:
+		return b.PPU.ReadOAM(addr - 0xFE00)
 	}
-	b.PPU.VDMALen = 0
+	return b.Read(addr)
 }
 
 func (b *Bus) GetKEY1() byte {
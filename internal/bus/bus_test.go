@@ -0,0 +1,136 @@
+package bus
+
+import (
+	"testing"
+
+	"gomeboy/internal/memory"
+)
+
+func newTestBus(t *testing.T) *Bus {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x134:], "TEST")
+	rom[0x143] = 0x80 // CGB
+	m, err := memory.NewMemory(rom, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBus(m)
+	b.PPU.IsCGB = true
+	b.SetPostBootState()
+	return b
+}
+
+// The HDMA source is read even while the PPU locks VRAM for the CPU.
+func TestHDMATransferIgnoresLock(t *testing.T) {
+	b := newTestBus(t)
+	for i := uint16(0); i < 0x10; i++ {
+		b.PPU.WriteVRAM(i, byte(i+1))
+	}
+	b.PPU.Step(100) // Mode3
+	if !b.isVRAMLocked() {
+		t.Fatal("VRAM is not locked in Mode3")
+	}
+
+	b.PPU.VDMASrc = 0x8000
+	b.PPU.VDMADst = 0x1000
+	b.PPU.VDMALen = 0x10
+	b.IsGDMATransferInProgress = true
+	if cycles := b.HDMATransfer(); cycles != 32 {
+		t.Errorf("HDMATransfer() = %d cycles, want 32", cycles)
+	}
+	for i := uint16(0); i < 0x10; i++ {
+		if got := b.PPU.ReadVRAM(0x1000 + i); got != byte(i+1) {
+			t.Errorf("VRAM[$%04X] = $%02X, want $%02X", 0x9000+i, got, i+1)
+		}
+	}
+	if b.IsGDMATransferInProgress {
+		t.Error("transfer is still in progress after the last block")
+	}
+}
+
+// The CPU cannot access VRAM in mode 3, and OAM in modes 2 and 3,
+// unless Bus.IsPPUMemoryFree is set.
+func TestPPUMemoryLock(t *testing.T) {
+	tests := []struct {
+		name         string
+		dots         int // From the start of the first line after the boot (LCD on)
+		isLCDOff     bool
+		isVRAMLocked bool
+		isOAMLocked  bool
+	}{
+		{name: "mode 2", dots: 456 + 40, isOAMLocked: true},
+		{name: "mode 3", dots: 456 + 100, isVRAMLocked: true, isOAMLocked: true},
+		{name: "mode 0", dots: 456 + 300},
+		{name: "mode 1", dots: 456*145 + 100},
+		{name: "LCD off", dots: 456 + 100, isLCDOff: true},
+	}
+	for _, tt := range tests {
+		for _, isFree := range []bool{false, true} {
+			b := newTestBus(t)
+			b.IsPPUMemoryFree = isFree
+			b.PPU.WriteVRAM(0x0000, 0x12)
+			b.PPU.WriteOAM(0x00, 0x34)
+			b.PPU.Step(tt.dots)
+			if tt.isLCDOff {
+				b.Write(LCDC, b.Read(LCDC)&^(1<<7))
+			}
+
+			wantVRAM, wantOAM := byte(0x12), byte(0x34)
+			if tt.isVRAMLocked && !isFree {
+				wantVRAM = 0xFF
+			}
+			if tt.isOAMLocked && !isFree {
+				wantOAM = 0xFF
+			}
+			if got := b.Read(0x8000); got != wantVRAM {
+				t.Errorf("%s (free = %v): read VRAM = $%02X, want $%02X", tt.name, isFree, got, wantVRAM)
+			}
+			if got := b.Read(0xFE00); got != wantOAM {
+				t.Errorf("%s (free = %v): read OAM = $%02X, want $%02X", tt.name, isFree, got, wantOAM)
+			}
+
+			b.Write(0x8000, 0x56)
+			b.Write(0xFE00, 0x78)
+			wantVRAM, wantOAM = 0x56, 0x78
+			if tt.isVRAMLocked && !isFree {
+				wantVRAM = 0x12
+			}
+			if tt.isOAMLocked && !isFree {
+				wantOAM = 0x34
+			}
+			if got := b.PPU.ReadVRAM(0x0000); got != wantVRAM {
+				t.Errorf("%s (free = %v): VRAM after a write = $%02X, want $%02X", tt.name, isFree, got, wantVRAM)
+			}
+			if got := b.PPU.ReadOAM(0x00); got != wantOAM {
+				t.Errorf("%s (free = %v): OAM after a write = $%02X, want $%02X", tt.name, isFree, got, wantOAM)
+			}
+		}
+	}
+}
+
+// The OAM DMA reads VRAM even in mode 3, where the CPU reads $FF.
+func TestDMATransferFromVRAM(t *testing.T) {
+	b := newTestBus(t)
+	for i := uint16(0); i < 160; i++ {
+		b.PPU.WriteVRAM(i, byte(i+1))
+	}
+	b.PPU.Step(456 + 100) // Mode3
+	if !b.isVRAMLocked() {
+		t.Fatal("VRAM is not locked in Mode3")
+	}
+
+	b.Write(DMA, 0x80)
+	for i := 0; i < 160; i++ {
+		b.DMATransfer()
+	}
+	if b.IsDMATransferInProgress {
+		t.Error("transfer is still in progress after 160 bytes")
+	}
+	for i := uint16(0); i < 160; i++ {
+		if got := b.PPU.ReadOAM(i); got != byte(i+1) {
+			t.Errorf("OAM[$%02X] = $%02X, want $%02X", i, got, i+1)
+			break
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package bus
+
+import "gomeboy/internal/savestate"
+
+// The SaveState writes the Bus state followed by all the devices connected to it.
+func (b *Bus) SaveState(e *savestate.Encoder) {
+	e.Write(b.IsDMATransferInProgress)
+	e.WriteInt(b.DMATransferIndex)
+	e.Write(b.IsGDMATransferInProgress)
+	e.Write(b.IsWSpeed)
+	e.Write(b.IsSwitchArmed)
+
+	b.Memory.SaveState(e)
+	b.PPU.SaveState(e)
+	b.Timer.SaveState(e)
+	b.Joypad.SaveState(e)
+	b.Serial.SaveState(e)
+	b.APU.SaveState(e)
+}
+
+func (b *Bus) LoadState(d *savestate.Decoder) {
+	d.Read(&b.IsDMATransferInProgress)
+	d.ReadInt(&b.DMATransferIndex)
+	d.Check(b.DMATransferIndex >= 0 && b.DMATransferIndex < 160, "OAM DMA index")
+	d.Read(&b.IsGDMATransferInProgress)
+	d.Read(&b.IsWSpeed)
+	d.Read(&b.IsSwitchArmed)
+
+	b.Memory.LoadState(d)
+	b.PPU.LoadState(d)
+	d.Check(!b.IsDMATransferInProgress || b.PPU.GetDMA() <= 0xDF, "OAM DMA source")
+	b.Timer.LoadState(d)
+	b.Joypad.LoadState(d)
+	b.Serial.LoadState(d)
+	b.APU.LoadState(d)
+}
@@ -0,0 +1,160 @@
+// Package camera provides the images seen by the sensor of the Game Boy Camera.
+// No webcam is used; the image comes from a PNG file, a directory of PNG files,
+// or a generated test pattern.
+
+package camera
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The size of the image captured by the sensor
+const (
+	SensorWidth  = 128
+	SensorHeight = 112
+)
+
+// The Frame is a grayscale image of the sensor (0=black, 255=white).
+type Frame [SensorHeight][SensorWidth]byte
+
+// The Source supplies an image each time the camera captures.
+// The image is scaled to the sensor size.
+type Source interface {
+	Capture() image.Image
+}
+
+// The NewSource selects the source by the spec (e.g. config.toml):
+// "" or "test" is the test pattern, a directory is an image sequence, and a file is a static image.
+func NewSource(spec string) (Source, error) {
+	if spec == "" || spec == "test" {
+		return NewTestPattern(), nil
+	}
+	info, err := os.Stat(spec)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewSequence(spec)
+	}
+	return NewStatic(spec)
+}
+
+// The Grab captures an image from src and converts it to the sensor size (nearest neighbor).
+func Grab(src Source) *Frame {
+	img := src.Capture()
+	b := img.Bounds()
+	f := &Frame{}
+	for y := range SensorHeight {
+		for x := range SensorWidth {
+			sx := b.Min.X + x*b.Dx()/SensorWidth
+			sy := b.Min.Y + y*b.Dy()/SensorHeight
+			f[y][x] = color.GrayModel.Convert(img.At(sx, sy)).(color.Gray).Y
+		}
+	}
+	return f
+}
+
+// =============================================== Static ===============================================
+
+// The Static always captures the same image.
+type Static struct {
+	img image.Image
+}
+
+func NewStatic(path string) (*Static, error) {
+	img, err := readPNG(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Static{img: img}, nil
+}
+
+func (s *Static) Capture() image.Image {
+	return s.img
+}
+
+// ============================================== Sequence ==============================================
+
+// The Sequence captures the PNG files in a directory in name order, and loops.
+type Sequence struct {
+	paths []string
+	next  int
+}
+
+func NewSequence(dir string) (*Sequence, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sequence{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".png") {
+			s.paths = append(s.paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(s.paths) == 0 {
+		return nil, fmt.Errorf("camera: no PNG files in %s", dir)
+	}
+	sort.Strings(s.paths)
+	return s, nil
+}
+
+// A file that cannot be read is captured as a black image.
+func (s *Sequence) Capture() image.Image {
+	path := s.paths[s.next]
+	s.next = (s.next + 1) % len(s.paths)
+	img, err := readPNG(path)
+	if err != nil {
+		return image.NewGray(image.Rect(0, 0, SensorWidth, SensorHeight))
+	}
+	return img
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("camera: %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// ============================================ Test pattern ============================================
+
+// The TestPattern generates gray bars with a circle that moves on each capture.
+type TestPattern struct {
+	count int
+}
+
+func NewTestPattern() *TestPattern {
+	return &TestPattern{}
+}
+
+func (t *TestPattern) Capture() image.Image {
+	img := image.NewGray(image.Rect(0, 0, SensorWidth, SensorHeight))
+	cx := (t.count * 2) % SensorWidth
+	cy := SensorHeight / 2
+	for y := range SensorHeight {
+		for x := range SensorWidth {
+			v := byte(x / 16 * 255 / 7) // 8 bars from black to white
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy < 20*20 {
+				v = 255 - v
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	t.count++
+	return img
+}
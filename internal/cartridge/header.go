@@ -0,0 +1,218 @@
+// Package cartridge parses and validates the cartridge header (0100~014F).
+
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The header ends at 014F.
+const HeaderEnd = 0x0150
+
+var ErrTooSmall = errors.New("ROM is too small to have a cartridge header")
+
+type Header struct {
+	Title            string // 0134~0143 (0134~013E if there is a manufacturer code)
+	ManufacturerCode string // 013F~0142 (only some CGB cartridges)
+	CGBFlag          byte   // 0143: 0x80 = CGB enhanced, 0xC0 = CGB only
+	NewLicenseeCode  string // 0144~0145 (used if OldLicenseeCode is 0x33)
+	SGBFlag          byte   // 0146: 0x03 = SGB functions
+	CartType         byte   // 0147
+	ROMSizeCode      byte   // 0148
+	RAMSizeCode      byte   // 0149
+	Destination      byte   // 014A: 0x00 = Japan, 0x01 = Overseas
+	OldLicenseeCode  byte   // 014B
+	Version          byte   // 014C
+	HeaderChecksum   byte   // 014D
+	GlobalChecksum   uint16 // 014E~014F (big endian)
+
+	// Verified by Parse
+	IsHeaderChecksumValid bool
+	IsGlobalChecksumValid bool
+}
+
+// The Parse reads the header of the ROM, and verifies the checksums.
+// A wrong checksum is not an error, as some unlicensed cartridges have one.
+func Parse(rom []byte) (*Header, error) {
+	if len(rom) < HeaderEnd {
+		return nil, ErrTooSmall
+	}
+	h := &Header{
+		CGBFlag:         rom[0x0143],
+		NewLicenseeCode: string(rom[0x0144:0x0146]),
+		SGBFlag:         rom[0x0146],
+		CartType:        rom[0x0147],
+		ROMSizeCode:     rom[0x0148],
+		RAMSizeCode:     rom[0x0149],
+		Destination:     rom[0x014A],
+		OldLicenseeCode: rom[0x014B],
+		Version:         rom[0x014C],
+		HeaderChecksum:  rom[0x014D],
+		GlobalChecksum:  uint16(rom[0x014E])<<8 | uint16(rom[0x014F]),
+	}
+
+	// Old cartridges use 0143 for the title. CGB cartridges may have a manufacturer code at the end.
+	title := rom[0x0134:0x0144]
+	if h.IsCGB() {
+		title = rom[0x0134:0x0143]
+		if code := rom[0x013F:0x0143]; isManufacturerCode(code) {
+			h.ManufacturerCode = string(code)
+			title = rom[0x0134:0x013F]
+		}
+	}
+	h.Title = trimTitle(title)
+
+	h.IsHeaderChecksumValid = ComputeHeaderChecksum(rom) == h.HeaderChecksum
+	h.IsGlobalChecksumValid = ComputeGlobalChecksum(rom) == h.GlobalChecksum
+	return h, nil
+}
+
+// The title is padded with 0x00 (or spaces).
+func trimTitle(b []byte) string {
+	s := string(b)
+	if i := strings.IndexByte(s, 0); i != -1 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, " ")
+}
+
+// The manufacturer code is 4 uppercase letters (or digits) following an 11 characters title.
+// It is the game code of the product number (e.g. "AAXE" of CGB-AAXE-USA), so the first letter is the type
+// (A/B = normal, H = with RTC or IR, K = tilt, V = rumble) and the last one is the destination.
+// (Otherwise, it is the end of a 15 characters title in uppercase.)
+func isManufacturerCode(b []byte) bool {
+	for _, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return strings.IndexByte("ABHKV", b[0]) != -1 && strings.IndexByte("ADEFIJKPSUXY", b[3]) != -1
+}
+
+// The header checksum covers 0134~014C. The boot ROM refuses to start if it is wrong.
+func ComputeHeaderChecksum(rom []byte) byte {
+	x := byte(0)
+	for _, b := range rom[0x0134:0x014D] {
+		x = x - b - 1
+	}
+	return x
+}
+
+// The global checksum is the sum of all bytes except itself. (Not checked by the hardware)
+func ComputeGlobalChecksum(rom []byte) uint16 {
+	sum := uint16(0)
+	for i, b := range rom {
+		if i != 0x014E && i != 0x014F {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+func (h *Header) IsCGB() bool {
+	return h.CGBFlag == 0x80 || h.CGBFlag == 0xC0
+}
+
+func (h *Header) IsCGBOnly() bool {
+	return h.CGBFlag == 0xC0
+}
+
+// SGB functions are enabled only with the old licensee code 0x33.
+func (h *Header) IsSGB() bool {
+	return h.SGBFlag == 0x03 && h.OldLicenseeCode == 0x33
+}
+
+// The LicenseeCode returns the new licensee code if it is used, or the old one in hex.
+func (h *Header) LicenseeCode() string {
+	if h.OldLicenseeCode == 0x33 {
+		return h.NewLicenseeCode
+	}
+	return fmt.Sprintf("%02X", h.OldLicenseeCode)
+}
+
+// The ROMBanks returns the number of 16KiB ROM banks, or -1 for an unknown size code.
+func (h *Header) ROMBanks() int {
+	switch {
+	case h.ROMSizeCode <= 0x08:
+		return 2 << h.ROMSizeCode
+	case h.ROMSizeCode == 0x52:
+		return 72
+	case h.ROMSizeCode == 0x53:
+		return 80
+	case h.ROMSizeCode == 0x54:
+		return 96
+	default:
+		return -1
+	}
+}
+
+// The RAMBanks returns the number of 8KiB RAM banks, or -1 for an unknown size code.
+// (0x01 is unused; some homebrew uses it for 2KiB, which is treated as no RAM.)
+func (h *Header) RAMBanks() int {
+	switch h.RAMSizeCode {
+	case 0x00, 0x01:
+		return 0
+	case 0x02:
+		return 1
+	case 0x03:
+		return 4
+	case 0x04:
+		return 16
+	case 0x05:
+		return 8
+	default:
+		return -1
+	}
+}
+
+func (h *Header) CartTypeName() string {
+	if name, ok := cartTypeNames[h.CartType]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+func (h *Header) DestinationName() string {
+	switch h.Destination {
+	case 0x00:
+		return "Japan"
+	case 0x01:
+		return "Overseas"
+	default:
+		return "Unknown"
+	}
+}
+
+// Names of the cartridge types (0147)
+var cartTypeNames = map[byte]string{
+	0x00: "ROM ONLY",
+	0x01: "MBC1",
+	0x02: "MBC1+RAM",
+	0x03: "MBC1+RAM+BATTERY",
+	0x05: "MBC2",
+	0x06: "MBC2+BATTERY",
+	0x08: "ROM+RAM",
+	0x09: "ROM+RAM+BATTERY",
+	0x0B: "MMM01",
+	0x0C: "MMM01+RAM",
+	0x0D: "MMM01+RAM+BATTERY",
+	0x0F: "MBC3+TIMER+BATTERY",
+	0x10: "MBC3+TIMER+RAM+BATTERY",
+	0x11: "MBC3",
+	0x12: "MBC3+RAM",
+	0x13: "MBC3+RAM+BATTERY",
+	0x19: "MBC5",
+	0x1A: "MBC5+RAM",
+	0x1B: "MBC5+RAM+BATTERY",
+	0x1C: "MBC5+RUMBLE",
+	0x1D: "MBC5+RUMBLE+RAM",
+	0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+	0x20: "MBC6",
+	0x22: "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+	0xFC: "POCKET CAMERA",
+	0xFD: "BANDAI TAMA5",
+	0xFE: "HuC3",
+	0xFF: "HuC1+RAM+BATTERY",
+}
@@ -0,0 +1,153 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+type headerFixture struct {
+	title    string // Written from 0134
+	code     string // Written from 013F if set
+	cgbFlag  byte
+	sgbFlag  byte
+	cartType byte
+	romSize  byte
+	ramSize  byte
+	oldLic   byte
+	newLic   string
+}
+
+// The newROM returns a 32KiB ROM with the header of f and the correct checksums.
+func (f headerFixture) newROM() []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x0134:], f.title)
+	copy(rom[0x013F:], f.code)
+	if f.cgbFlag != 0 {
+		rom[0x0143] = f.cgbFlag
+	}
+	copy(rom[0x0144:], f.newLic)
+	rom[0x0146] = f.sgbFlag
+	rom[0x0147] = f.cartType
+	rom[0x0148] = f.romSize
+	rom[0x0149] = f.ramSize
+	rom[0x014B] = f.oldLic
+	rom[0x014D] = ComputeHeaderChecksum(rom)
+	sum := ComputeGlobalChecksum(rom)
+	rom[0x014E], rom[0x014F] = byte(sum>>8), byte(sum)
+	return rom
+}
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  headerFixture
+		wantName string
+		wantCode string
+	}{
+		{name: "DMG 16 characters", fixture: headerFixture{title: "ABCDEFGHIJKLMNOP"}, wantName: "ABCDEFGHIJKLMNOP"},
+		{name: "DMG padded", fixture: headerFixture{title: "TETRIS"}, wantName: "TETRIS"},
+		{name: "DMG padded with spaces", fixture: headerFixture{title: "TETRIS          "}, wantName: "TETRIS"},
+		{name: "CGB without code", fixture: headerFixture{title: "GAME", cgbFlag: 0x80}, wantName: "GAME"},
+		{name: "CGB with code", fixture: headerFixture{title: "POKEMON_SLV", code: "AAXE", cgbFlag: 0x80}, wantName: "POKEMON_SLV", wantCode: "AAXE"},
+		{name: "CGB only with code", fixture: headerFixture{title: "PM_CRYSTAL", code: "BYTE", cgbFlag: 0xC0}, wantName: "PM_CRYSTAL", wantCode: "BYTE"},
+		{name: "CGB rumble code", fixture: headerFixture{title: "GAME", code: "VPHE", cgbFlag: 0x80}, wantName: "GAME", wantCode: "VPHE"},
+		{name: "CGB 15 characters in uppercase", fixture: headerFixture{title: "SUPER GAME TRIS", cgbFlag: 0x80}, wantName: "SUPER GAME TRIS"},
+		{name: "CGB 15 characters starting with a type letter", fixture: headerFixture{title: "TURBO RACE BOOM", cgbFlag: 0x80}, wantName: "TURBO RACE BOOM"},
+		{name: "CGB 15 characters with digits", fixture: headerFixture{title: "RACING 2000 GBC", cgbFlag: 0xC0}, wantName: "RACING 2000 GBC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Parse(tt.fixture.newROM())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Title != tt.wantName || h.ManufacturerCode != tt.wantCode {
+				t.Errorf("title, code = %q, %q, want %q, %q", h.Title, h.ManufacturerCode, tt.wantName, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		fixture     headerFixture
+		wantCGB     bool
+		wantCGBOnly bool
+		wantSGB     bool
+		wantLic     string
+	}{
+		{fixture: headerFixture{cgbFlag: 0x00, oldLic: 0x01}, wantLic: "01"},
+		{fixture: headerFixture{cgbFlag: 0x80}, wantCGB: true, wantLic: "00"},
+		{fixture: headerFixture{cgbFlag: 0xC0}, wantCGB: true, wantCGBOnly: true, wantLic: "00"},
+		{fixture: headerFixture{cgbFlag: 0x40}, wantLic: "00"},
+		{fixture: headerFixture{sgbFlag: 0x03, oldLic: 0x33, newLic: "01"}, wantSGB: true, wantLic: "01"},
+		{fixture: headerFixture{sgbFlag: 0x03, oldLic: 0x01}, wantLic: "01"}, // SGB needs 0x33.
+	}
+	for i, tt := range tests {
+		h, err := Parse(tt.fixture.newROM())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.IsCGB() != tt.wantCGB || h.IsCGBOnly() != tt.wantCGBOnly || h.IsSGB() != tt.wantSGB || h.LicenseeCode() != tt.wantLic {
+			t.Errorf("#%d: CGB=%v CGBOnly=%v SGB=%v licensee=%q, want %v %v %v %q", i,
+				h.IsCGB(), h.IsCGBOnly(), h.IsSGB(), h.LicenseeCode(), tt.wantCGB, tt.wantCGBOnly, tt.wantSGB, tt.wantLic)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	rom := headerFixture{title: "CHECKSUM", cartType: 0x01}.newROM()
+	h, err := Parse(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.IsHeaderChecksumValid || !h.IsGlobalChecksumValid {
+		t.Errorf("valid checksums reported as invalid: header=%v global=%v", h.IsHeaderChecksumValid, h.IsGlobalChecksumValid)
+	}
+
+	rom[0x0134] ^= 0xFF // Covered by both checksums
+	h, _ = Parse(rom)
+	if h.IsHeaderChecksumValid || h.IsGlobalChecksumValid {
+		t.Error("broken header reported as valid")
+	}
+
+	rom[0x0134] ^= 0xFF
+	rom[0x4000] = 0x12 // Covered only by the global checksum
+	h, _ = Parse(rom)
+	if !h.IsHeaderChecksumValid || h.IsGlobalChecksumValid {
+		t.Errorf("header=%v global=%v, want true false", h.IsHeaderChecksumValid, h.IsGlobalChecksumValid)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	romTests := []struct {
+		code byte
+		want int
+	}{
+		{0x00, 2}, {0x01, 4}, {0x05, 64}, {0x08, 512}, {0x52, 72}, {0x53, 80}, {0x54, 96}, {0x09, -1}, {0xFF, -1},
+	}
+	for _, tt := range romTests {
+		h := &Header{ROMSizeCode: tt.code}
+		if got := h.ROMBanks(); got != tt.want {
+			t.Errorf("ROM size code %02X: %d banks, want %d", tt.code, got, tt.want)
+		}
+	}
+	ramTests := []struct {
+		code byte
+		want int
+	}{
+		{0x00, 0}, {0x01, 0}, {0x02, 1}, {0x03, 4}, {0x04, 16}, {0x05, 8}, {0x06, -1},
+	}
+	for _, tt := range ramTests {
+		h := &Header{RAMSizeCode: tt.code}
+		if got := h.RAMBanks(); got != tt.want {
+			t.Errorf("RAM size code %02X: %d banks, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestParseTooSmall(t *testing.T) {
+	if _, err := Parse(make([]byte, HeaderEnd-1)); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("err = %v, want ErrTooSmall", err)
+	}
+}
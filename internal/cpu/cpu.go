@@ -20,9 +20,16 @@ type CPU struct {
 	imeDelay     int
 	cycles       int
 	isHaltBug    bool
+	breakpoint   *Registers // Registers at the last LD B, B (nil = not hit since TakeBreakpoint)
 	prevIF       byte
 }
 
+// Register values for tools outside the package (e.g. test ROM checks).
+type Registers struct {
+	A, F, B, C, D, E, H, L byte
+	SP, PC                 uint16
+}
+
 func NewCPU(b *bus.Bus) *CPU {
 	c := &CPU{
 		Bus: b,
@@ -40,11 +47,28 @@ func NewCPU(b *bus.Bus) *CPU {
 	return c
 }
 
+// The ResetForBootROM clears the registers set by NewCPU to the post-boot values,
+// and starts from 0x0000 where the boot ROM is mapped.
+func (c *CPU) ResetForBootROM() {
+	c.a, c.f, c.b, c.c, c.d, c.e, c.h, c.l = 0, 0, 0, 0, 0, 0, 0, 0
+	c.sp = 0x0000
+	c.pc = 0x0000
+}
+
 func (c *CPU) Step() int {
 	c.cycles = 0
 
 	c.checkIRQ()
 
+	// The HBlank DMA pauses during HALT, so the block for this HBlank is not copied.
+	if c.isHalted {
+		c.Bus.PPU.HasHDMARequested = false
+	}
+
+	if c.Bus.IsGDMATransferInProgress || c.Bus.PPU.HasHDMARequested {
+		return c.Bus.HDMATransfer()
+	}
+
 	if c.Bus.IsDMATransferInProgress {
 		c.Bus.DMATransfer()
 		return 4
@@ -87,6 +111,24 @@ func (c *CPU) Step() int {
 	return c.cycles
 }
 
+func (c *CPU) GetRegisters() Registers {
+	return Registers{
+		A: c.a, F: c.f, B: c.b, C: c.c, D: c.d, E: c.e, H: c.h, L: c.l,
+		SP: c.sp, PC: c.pc,
+	}
+}
+
+// The TakeBreakpoint returns the registers at the last LD B, B, and clears them.
+// ok is false if LD B, B has not been executed since the last call.
+func (c *CPU) TakeBreakpoint() (r Registers, ok bool) {
+	if c.breakpoint == nil {
+		return Registers{}, false
+	}
+	r = *c.breakpoint
+	c.breakpoint = nil
+	return r, true
+}
+
 func (c *CPU) GetBC() uint16 {
 	return (uint16(c.b) << 8) | uint16(c.c)
 }
@@ -236,6 +278,11 @@ func (c *CPU) checkIRQ() {
 		c.write(bus.IF, newIF)
 		c.Bus.Timer.HasIRQ = false
 	}
+	if c.Bus.Serial.HasIRQ {
+		newIF := c.read(bus.IF) | (1 << 3)
+		c.write(bus.IF, newIF)
+		c.Bus.Serial.HasIRQ = false
+	}
 	if c.Bus.Joypad.HasIRQ {
 		newIF := c.read(bus.IF) | (1 << 4)
 		c.write(bus.IF, newIF)
@@ -0,0 +1,89 @@
+package cpu
+
+import (
+	"testing"
+
+	"gomeboy/internal/bus"
+	"gomeboy/internal/memory"
+)
+
+func newTestCPU(t *testing.T) *CPU {
+	t.Helper()
+	rom := make([]byte, 0x8000)
+	copy(rom[0x134:], "TEST")
+	rom[0x143] = 0x80 // CGB
+	m, err := memory.NewMemory(rom, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bus.NewBus(m)
+	b.PPU.IsCGB = true
+	b.SetPostBootState()
+	return NewCPU(b)
+}
+
+// The HBlank DMA does not copy any block while the CPU is halted.
+func TestHBlankDMAPausedByHALT(t *testing.T) {
+	c := newTestCPU(t)
+	c.Bus.PPU.VDMASrc = 0xC000
+	c.Bus.PPU.VDMALen = 0x20
+	c.Bus.PPU.IsHBlankDMAActive = true
+	c.write(bus.IE, 0x00)
+	c.write(bus.IF, 0x00)
+	c.isHalted = true
+
+	c.Bus.PPU.HasHDMARequested = true
+	if cycles := c.Step(); cycles != 4 {
+		t.Errorf("Step() = %d cycles while halted, want 4", cycles)
+	}
+	if c.Bus.PPU.VDMALen != 0x20 {
+		t.Errorf("VDMALen = $%X while halted, want $20", c.Bus.PPU.VDMALen)
+	}
+	if c.Bus.PPU.HasHDMARequested {
+		t.Error("HBlank DMA request is kept during HALT")
+	}
+
+	c.isHalted = false
+	c.Bus.PPU.HasHDMARequested = true
+	if cycles := c.Step(); cycles != 32 {
+		t.Errorf("Step() = %d cycles for a block, want 32", cycles)
+	}
+	if c.Bus.PPU.VDMALen != 0x10 {
+		t.Errorf("VDMALen = $%X after a block, want $10", c.Bus.PPU.VDMALen)
+	}
+}
+
+// The HBlank DMA started in HBlank or with the LCD off copies the first block at once,
+// and otherwise waits for the next HBlank.
+func TestHBlankDMAStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		dots     int // From the start of the first line after the boot (LCD on)
+		isLCDOff bool
+		want     int // VDMALen after the next Step
+	}{
+		{name: "mode 0", dots: 456 + 300, want: 0x10},
+		{name: "LCD off", dots: 456 + 100, isLCDOff: true, want: 0x10},
+		{name: "mode 2", dots: 456 + 40, want: 0x20},
+		{name: "mode 3", dots: 456 + 100, want: 0x20},
+		{name: "mode 1", dots: 456*145 + 100, want: 0x20},
+	}
+	for _, tt := range tests {
+		c := newTestCPU(t)
+		c.write(bus.IE, 0x00)
+		c.Bus.PPU.Step(tt.dots)
+		if tt.isLCDOff {
+			c.write(bus.LCDC, c.read(bus.LCDC)&^(1<<7))
+		}
+		c.write(bus.HDMA1, 0xC0)
+		c.write(bus.HDMA2, 0x00)
+		c.write(bus.HDMA3, 0x00)
+		c.write(bus.HDMA4, 0x00)
+		c.write(bus.HDMA5, 0x81) // HBlank DMA, 2 blocks
+
+		c.Step()
+		if c.Bus.PPU.VDMALen != tt.want {
+			t.Errorf("%s: VDMALen = $%X after starting the HBlank DMA, want $%X", tt.name, c.Bus.PPU.VDMALen, tt.want)
+		}
+	}
+}
@@ -148,7 +148,13 @@ func (c *CPU) ld_r_r(dst *byte, src byte) {
 	*dst = src
 	c.cycles += 4
 }
-func (c *CPU) opLD_B_B() { c.ld_r_r(&c.b, c.b) } // 40
+func (c *CPU) opLD_B_B() { // 40
+	c.ld_r_r(&c.b, c.b)
+	// Mooneye test ROMs use LD B, B as a software breakpoint.
+	// The registers are kept now, as the ROM may change them before the frame ends.
+	r := c.GetRegisters()
+	c.breakpoint = &r
+}
 func (c *CPU) opLD_D_B() { c.ld_r_r(&c.d, c.b) } // 50
 func (c *CPU) opLD_H_B() { c.ld_r_r(&c.h, c.b) } // 60
 func (c *CPU) opLD_B_C() { c.ld_r_r(&c.b, c.c) } // 41
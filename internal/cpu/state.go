@@ -0,0 +1,29 @@
+package cpu
+
+import "gomeboy/internal/savestate"
+
+func (c *CPU) SaveState(e *savestate.Encoder) {
+	e.Write([8]byte{c.a, c.f, c.b, c.c, c.d, c.e, c.h, c.l})
+	e.Write(c.sp)
+	e.Write(c.pc)
+	e.Write(c.IsStopped)
+	e.Write(c.isHalted)
+	e.Write(c.isIMEEnabled)
+	e.Write(c.isHaltBug)
+	e.WriteInt(c.imeDelay)
+	e.Write(c.prevIF)
+}
+
+func (c *CPU) LoadState(d *savestate.Decoder) {
+	var regs [8]byte
+	d.Read(&regs)
+	c.a, c.f, c.b, c.c, c.d, c.e, c.h, c.l = regs[0], regs[1], regs[2], regs[3], regs[4], regs[5], regs[6], regs[7]
+	d.Read(&c.sp)
+	d.Read(&c.pc)
+	d.Read(&c.IsStopped)
+	d.Read(&c.isHalted)
+	d.Read(&c.isIMEEnabled)
+	d.Read(&c.isHaltBug)
+	d.ReadInt(&c.imeDelay)
+	d.Read(&c.prevIF)
+}
@@ -0,0 +1,9 @@
+package emulator
+
+import "gomeboy/internal/camera"
+
+// The SetCameraSource sets the image seen by the Game Boy Camera.
+// It returns false if the cartridge is not a camera.
+func (e *Emulator) SetCameraSource(src camera.Source) bool {
+	return e.CPU.Bus.Memory.SetImageSource(src)
+}
@@ -1,119 +1,221 @@
 package emulator
 
 import (
+	"fmt"
 	"gomeboy/internal/bus"
+	"gomeboy/internal/cartridge"
 	"gomeboy/internal/cpu"
 	"gomeboy/internal/memory"
-	"strings"
-
-	"github.com/hajimehoshi/ebiten/v2"
+	"gomeboy/internal/movie"
+	"gomeboy/internal/ppu"
+	"gomeboy/internal/rewind"
+	"hash/crc32"
 )
 
 const (
 	CyclesPerFrame float64 = 4194304.0 / 60.0
 )
 
+// The Input supplies the joypad state for each frame.
+// (Buttons returns a combination of joypad.ButtonXX, Pressed=1)
+type Input interface {
+	Buttons() byte
+}
+
+// An Input that also implements the TiltInput supplies the tilt of the cartridge
+// for each frame. (MBC7, see SetTilt)
+type TiltInput interface {
+	Tilt() (x, y float64)
+}
+
+// The Options are the optional settings for NewEmulator.
+type Options struct {
+	DMGBootROM []byte // If set, used for DMG cartridges.
+	CGBBootROM []byte // If set, used for CGB cartridges.
+	Mapper     string // If set, overrides the mapper in the cartridge header. (e.g. "mbc1", see mbc.MapperNames)
+	Renderer   string // "scanline" (default) or "fifo" (see ppu.RendererNames)
+
+	// If true, the CPU can access VRAM/OAM while the PPU uses them (see bus.Bus.IsPPUMemoryFree).
+	IsPPUMemoryFree bool
+}
+
 type Emulator struct {
 	CPU       *cpu.CPU
+	Input     Input // If nil, no buttons are pressed.
 	cpuCycles float64
 
-	IsPaused    bool
-	IsPauseMode bool
-	IsCGB       bool
-	ROMTitle    string
+	IsPaused   bool
+	IsCGB      bool
+	ROMTitle   string
+	FrameCount int // Number of frames run since power-on
+
+	romChecksum     uint32         // To reject save states of other ROMs
+	bootROMChecksum uint32         // 0 = Started without a boot ROM
+	rewind          *rewind.Buffer // nil = Rewind is disabled
+	movie           *movie.Movie   // Being recorded or played (nil = none)
+	isPlayingMovie  bool
+	movieFrame      int  // Next frame of the movie to play
+	isPowerOn       bool // No frame has run and no state has been loaded
+}
+
+// Errors of NewEmulator (see the memory package)
+var ErrROMTooSmall = memory.ErrROMTooSmall
+
+// The UnknownRendererError is returned when Options.Renderer is not in ppu.RendererNames.
+type UnknownRendererError struct {
+	Name string
+}
 
-	isKeyP       bool
-	isKeyS       bool
-	isKeyEsc     bool
-	isPrevKeyP   bool
-	isPrevKeyS   bool
-	isPrevKeyEsc bool
+func (err *UnknownRendererError) Error() string {
+	return fmt.Sprintf("unknown renderer %q (expected \"scanline\" or \"fifo\")", err.Name)
 }
 
-func NewEmulator(rom, sav []byte) *Emulator {
-	m := memory.NewMemory(rom, sav)
+type (
+	UnsupportedMapperError  = memory.UnsupportedMapperError
+	HeaderSizeMismatchError = memory.HeaderSizeMismatchError
+	BootROMSizeError        = memory.BootROMSizeError
+	SaveSizeError           = memory.SaveSizeError
+)
+
+// The NewEmulator returns an error if the cartridge cannot be emulated
+// (ErrROMTooSmall, *UnsupportedMapperError, *HeaderSizeMismatchError, *BootROMSizeError,
+// *SaveSizeError or *UnknownRendererError).
+func NewEmulator(rom, sav []byte, opts Options) (*Emulator, error) {
+	m, err := memory.NewMemory(rom, sav, opts.Mapper)
+	if err != nil {
+		return nil, err
+	}
 	b := bus.NewBus(m)
 	c := cpu.NewCPU(b)
 	c.Tracer = cpu.NewTracer(c)
 
 	e := &Emulator{
 		CPU:         c,
-		IsPauseMode: false,
 		IsPaused:    false,
+		romChecksum: crc32.ChecksumIEEE(rom),
+		isPowerOn:   true,
 	}
 
-	e.ROMTitle = e.GetROMTitle(rom)
+	e.ROMTitle = m.Header.Title
+	e.CPU.Bus.IsPPUMemoryFree = opts.IsPPUMemoryFree
 
-	cgbReg := e.CPU.Bus.Read(0x0143)
-	if cgbReg == 0xC0 || cgbReg == 0x80 {
+	if opts.Renderer != "" {
+		r, ok := ppu.RendererNames[opts.Renderer]
+		if !ok {
+			return nil, &UnknownRendererError{Name: opts.Renderer}
+		}
+		e.CPU.Bus.PPU.Renderer = r
+	}
+
+	if m.Header.IsCGB() {
 		e.IsCGB = true
 		e.CPU.Bus.PPU.IsCGB = true
+		e.CPU.Bus.Serial.IsCGB = true
 		e.CPU.Bus.PPU.SetOPRI(0xFE)
 	}
-	return e
+
+	bootROM := opts.DMGBootROM
+	if e.IsCGB {
+		bootROM = opts.CGBBootROM
+	}
+	if bootROM != nil {
+		if err := e.startFromBootROM(bootROM); err != nil {
+			return nil, err
+		}
+	} else {
+		e.CPU.Bus.SetPostBootState()
+	}
+	return e, nil
+}
+
+// Without a boot ROM, the emulator starts in the post-boot state.
+// With a boot ROM, it starts in the power-on state (the CPU and I/O registers cleared, LCD off),
+// and the boot ROM itself sets up the registers and the LCD.
+func (e *Emulator) startFromBootROM(bootROM []byte) error {
+	if err := e.CPU.Bus.Memory.SetBootROM(bootROM); err != nil {
+		return err
+	}
+	e.bootROMChecksum = crc32.ChecksumIEEE(bootROM)
+	e.CPU.ResetForBootROM()
+	e.CPU.Tracer = cpu.NewTracer(e.CPU)
+	return nil
 }
 
+// The RunFrame runs the emulation for one frame.
+// It returns -1 if the CPU has panicked.
 func (e *Emulator) RunFrame() int {
-	cpuSpeed := 1
-	if e.CPU.Bus.PPU.IsCGB && e.CPU.Bus.IsWSpeed {
-		cpuSpeed = 2
+	if e.Input != nil {
+		if !e.IsPlayingMovie() {
+			e.CPU.Bus.Joypad.SetButtons(e.Input.Buttons())
+		}
+		if t, ok := e.Input.(TiltInput); ok {
+			e.SetTilt(t.Tilt())
+		}
+	}
+	if e.IsPaused {
+		return 0
+	}
+	e.updateMovie()
+	if e.runFrame() == -1 {
+		return -1
+	}
+	if e.rewind != nil && e.FrameCount%e.rewind.Interval == 0 {
+		e.captureRewind()
 	}
-	maxCycles := CyclesPerFrame * float64(cpuSpeed)
-	e.CPU.Bus.Joypad.Update()
+	return 0
+}
+
+func (e *Emulator) runFrame() int {
+	maxCycles := CyclesPerFrame * float64(e.getCPUSpeed())
 	for e.cpuCycles < maxCycles {
-		e.updateEbitenKeys()
-		e.updateEmuMode()
-		if e.CPU.IsPanic || e.isKeyEsc { // for debug
-			e.panicDump()
+		if e.CPU.IsPanic {
+			e.DumpTrace()
 			return -1
-		} else if e.IsPaused {
-			return 0
 		}
-		var c int
-		c = e.CPU.Step()
-		e.CPU.Bus.Timer.Step(c, e.CPU.IsStopped)
-		e.CPU.Bus.PPU.Step(c / cpuSpeed)
-		e.CPU.Bus.APU.Step(c / cpuSpeed)
-		e.CPU.Tracer.Record(e.CPU)
-		e.cpuCycles += float64(c)
+		e.cpuCycles += float64(e.step())
 	}
 	e.cpuCycles -= maxCycles
+	e.FrameCount++
+	e.isPowerOn = false
 	return 0
 }
 
-// KeyP: Toggle Run/Pause Mode
-// KeyS: Run a single step
-func (e *Emulator) updateEmuMode() {
-	if e.isKeyP {
-		e.IsPauseMode = !e.IsPauseMode
-	}
-	e.IsPaused = e.IsPauseMode && !e.isKeyS
+// The StepInstruction runs only a single instruction (for debugging while paused).
+func (e *Emulator) StepInstruction() {
+	e.cpuCycles += float64(e.step())
+	e.isPowerOn = false
 }
 
-// In case of Panic, CPU status is output to the console.
-func (e *Emulator) panicDump() {
-	e.CPU.Tracer.Dump()
+func (e *Emulator) step() int {
+	cpuSpeed := e.getCPUSpeed()
+	c := e.CPU.Step()
+	e.CPU.Bus.Timer.Step(c, e.CPU.IsStopped)
+	e.CPU.Bus.Serial.Step(c)
+	e.CPU.Bus.PPU.Step(c / cpuSpeed)
+	e.CPU.Bus.APU.Step(c / cpuSpeed)
+	e.CPU.Bus.Memory.Step(c / cpuSpeed)
+	e.CPU.Tracer.Record(e.CPU)
+	return c
 }
 
-func (e *Emulator) updateEbitenKeys() {
-	isP := ebiten.IsKeyPressed(ebiten.KeyP)
-	isS := ebiten.IsKeyPressed(ebiten.KeyS)
-	isEsc := ebiten.IsKeyPressed(ebiten.KeyEscape)
-	e.isKeyP = !e.isPrevKeyP && isP
-	e.isKeyS = !e.isPrevKeyS && isS
-	e.isKeyEsc = !e.isPrevKeyEsc && isEsc
-	e.isPrevKeyP = isP
-	e.isPrevKeyS = isS
-	e.isPrevKeyEsc = isEsc
+func (e *Emulator) getCPUSpeed() int {
+	if e.CPU.Bus.PPU.IsCGB && e.CPU.Bus.IsWSpeed {
+		return 2
+	}
+	return 1
+}
+
+// The DumpTrace outputs the recent CPU status to the console.
+func (e *Emulator) DumpTrace() {
+	e.CPU.Tracer.Dump()
 }
 
 func (e *Emulator) GetROMTitle(rom []byte) string {
-	s := string(rom[0x0134:0x0143])
-	firstNullIdx := strings.IndexByte(s, 0)
-	if firstNullIdx != -1 {
-		s = s[:firstNullIdx]
+	h, err := cartridge.Parse(rom)
+	if err != nil {
+		return ""
 	}
-	return s
+	return h.Title
 }
 
 func (e *Emulator) GetDebugLog() []string {
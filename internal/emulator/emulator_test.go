@@ -0,0 +1,179 @@
+package emulator
+
+import (
+	"errors"
+	"gomeboy/internal/joypad"
+	"hash/crc32"
+	"testing"
+)
+
+// The inputProgram selects the d-pad, and adds P1 to B every loop.
+// B is written to BGP, so the screen depends on every input so far.
+// The joypad interrupt counts the press edges in D. (The handler is set by newTestROM)
+var inputProgram = []byte{
+	0x3E, 0x20, // LD A, $20
+	0xE0, 0x00, // LDH ($00), A
+	0x3E, 0x10, // LD A, $10
+	0xE0, 0xFF, // LDH ($FF), A
+	0xFB,       // EI
+	0xF0, 0x00, // loop: LDH A, ($00)
+	0x80,       // ADD A, B
+	0x47,       // LD B, A
+	0xE0, 0x47, // LDH ($47), A
+	0x18, 0xF8, // JR loop
+}
+
+// The newTestROM returns a 32 KiB ROM (No MBC) that jumps to the program at 0x0150.
+// The joypad interrupt handler increments D.
+func newTestROM(program []byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x60:], []byte{0x14, 0xD9})              // INC D; RETI
+	copy(rom[0x100:], []byte{0x00, 0xC3, 0x50, 0x01}) // NOP; JP $0150
+	copy(rom[0x134:], "TEST")
+	copy(rom[0x150:], program)
+	return rom
+}
+
+func newTestEmulator(t *testing.T, program []byte) *Emulator {
+	t.Helper()
+	emu, err := NewEmulator(newTestROM(program), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return emu
+}
+
+func runFrames(t *testing.T, emu *Emulator, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if emu.RunFrame() == -1 {
+			t.Fatalf("CPU panicked at frame %d", emu.FrameCount+1)
+		}
+	}
+}
+
+func getScreenHash(emu *Emulator) uint32 {
+	return crc32.ChecksumIEEE(emu.CPU.Bus.PPU.GetGameScreen().Pix)
+}
+
+// The scriptedInput presses the buttons of the script in turn, one entry per frame.
+type scriptedInput struct {
+	script []byte
+	frame  int
+}
+
+func (in *scriptedInput) Buttons() byte {
+	b := in.script[in.frame%len(in.script)]
+	in.frame++
+	return b
+}
+
+// The RunFrame runs without any frontend, taking the buttons from the Input.
+func TestRunFrameWithInput(t *testing.T) {
+	emu := newTestEmulator(t, inputProgram)
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonRight, 0}}
+	runFrames(t, emu, 10)
+
+	if emu.FrameCount != 10 {
+		t.Errorf("FrameCount = %d, want 10", emu.FrameCount)
+	}
+	if d := emu.CPU.GetRegisters().D; d != 5 {
+		t.Errorf("joypad interrupts = %d, want 5 (one per press)", d)
+	}
+
+	// The same inputs give the same screen, and other inputs give another one.
+	same := newTestEmulator(t, inputProgram)
+	same.Input = &scriptedInput{script: []byte{joypad.ButtonRight, 0}}
+	runFrames(t, same, 10)
+	other := newTestEmulator(t, inputProgram)
+	other.Input = &scriptedInput{script: []byte{joypad.ButtonLeft}}
+	runFrames(t, other, 10)
+	if getScreenHash(same) != getScreenHash(emu) {
+		t.Error("the same inputs gave different screens")
+	}
+	if getScreenHash(other) == getScreenHash(emu) {
+		t.Error("other inputs gave the same screen")
+	}
+
+	// While paused, the Input is still read but no frame runs.
+	emu.IsPaused = true
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonA}}
+	runFrames(t, emu, 3)
+	if emu.FrameCount != 10 {
+		t.Errorf("FrameCount while paused = %d, want 10", emu.FrameCount)
+	}
+	if b := emu.CPU.Bus.Joypad.GetButtons(); b != joypad.ButtonA {
+		t.Errorf("buttons while paused = %#x, want %#x", b, joypad.ButtonA)
+	}
+}
+
+// Without an Input, no buttons are pressed.
+func TestRunFrameWithoutInput(t *testing.T) {
+	emu := newTestEmulator(t, inputProgram)
+	runFrames(t, emu, 5)
+	if b := emu.CPU.Bus.Joypad.GetButtons(); b != 0 {
+		t.Errorf("buttons = %#x, want 0", b)
+	}
+	if d := emu.CPU.GetRegisters().D; d != 0 {
+		t.Errorf("joypad interrupts = %d, want 0", d)
+	}
+}
+
+// The errors of the memory package reach the caller of NewEmulator as its own types.
+func TestNewEmulatorErrors(t *testing.T) {
+	bigHeader := newTestROM(nil)
+	bigHeader[0x148] = 0x02 // 128KiB
+	tests := []struct {
+		name  string
+		rom   []byte
+		opts  Options
+		check func(err error) bool
+	}{
+		{
+			name:  "ROM too small",
+			rom:   make([]byte, 0x4000),
+			check: func(err error) bool { return errors.Is(err, ErrROMTooSmall) },
+		},
+		{
+			name: "unsupported mapper",
+			rom:  newTestROM(nil),
+			opts: Options{Mapper: "mbc6"},
+			check: func(err error) bool {
+				var e *UnsupportedMapperError
+				return errors.As(err, &e)
+			},
+		},
+		{
+			name: "header size mismatch",
+			rom:  bigHeader,
+			check: func(err error) bool {
+				var e *HeaderSizeMismatchError
+				return errors.As(err, &e)
+			},
+		},
+		{
+			name: "boot ROM size",
+			rom:  newTestROM(nil),
+			opts: Options{DMGBootROM: make([]byte, 0x200)},
+			check: func(err error) bool {
+				var e *BootROMSizeError
+				return errors.As(err, &e) && e.Size == 0x200
+			},
+		},
+		{
+			name: "unknown renderer",
+			rom:  newTestROM(nil),
+			opts: Options{Renderer: "gpu"},
+			check: func(err error) bool {
+				var e *UnknownRendererError
+				return errors.As(err, &e) && e.Name == "gpu"
+			},
+		},
+	}
+	for _, tt := range tests {
+		emu, err := NewEmulator(tt.rom, nil, tt.opts)
+		if emu != nil || !tt.check(err) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"gomeboy/internal/movie"
+)
+
+var (
+	ErrMovieROMMismatch     = errors.New("movie was recorded with a different ROM")
+	ErrMovieBootROMMismatch = errors.New("movie was recorded with a different boot ROM setting")
+	ErrMovieNotAtPowerOn    = errors.New("movie starts from power-on, but the emulator has already run")
+)
+
+// The StartRecording records the buttons of each frame run from now on into the returned Movie.
+// Before the first frame (and before loading a state), the movie starts from power-on.
+// (The .sav file must not be loaded then, as the movie does not contain it.)
+// Otherwise, it starts from a save state taken now.
+func (e *Emulator) StartRecording() (*movie.Movie, error) {
+	m := &movie.Movie{
+		ROMChecksum:     e.romChecksum,
+		BootROMChecksum: e.bootROMChecksum,
+	}
+	if !e.isPowerOn {
+		var buf bytes.Buffer
+		if err := e.SaveState(&buf); err != nil {
+			return nil, err
+		}
+		m.State = buf.Bytes()
+	}
+	e.movie = m
+	e.isPlayingMovie = false
+	return m, nil
+}
+
+// The PlayMovie replaces the buttons from the Input with the ones of the movie until it ends.
+// A movie from power-on must be played on an Emulator that has not run yet,
+// created with the same boot ROM and without the .sav file.
+func (e *Emulator) PlayMovie(m *movie.Movie) error {
+	switch {
+	case m.ROMChecksum != e.romChecksum:
+		return ErrMovieROMMismatch
+	case m.IsFromPowerOn() && m.BootROMChecksum != e.bootROMChecksum:
+		return ErrMovieBootROMMismatch
+	case m.IsFromPowerOn() && !e.isPowerOn:
+		return ErrMovieNotAtPowerOn
+	}
+	if !m.IsFromPowerOn() {
+		if err := e.LoadState(bytes.NewReader(m.State)); err != nil {
+			return err
+		}
+	}
+	e.movie = m
+	e.isPlayingMovie = true
+	e.movieFrame = 0
+	return nil
+}
+
+// The StopMovie ends the recording or the playback.
+// Loading a state and rewinding also end it, as the frames after that no longer match the movie.
+func (e *Emulator) StopMovie() {
+	e.movie = nil
+	e.isPlayingMovie = false
+}
+
+func (e *Emulator) IsRecordingMovie() bool {
+	return e.movie != nil && !e.isPlayingMovie
+}
+
+func (e *Emulator) IsPlayingMovie() bool {
+	return e.movie != nil && e.isPlayingMovie
+}
+
+// The updateMovie is called just before each frame runs.
+func (e *Emulator) updateMovie() {
+	if e.movie == nil {
+		return
+	}
+	if !e.isPlayingMovie {
+		e.movie.Frames = append(e.movie.Frames, e.CPU.Bus.Joypad.GetButtons())
+		return
+	}
+	if e.movieFrame < len(e.movie.Frames) {
+		e.CPU.Bus.Joypad.SetButtons(e.movie.Frames[e.movieFrame])
+		e.movieFrame++
+	}
+	if e.movieFrame >= len(e.movie.Frames) {
+		e.StopMovie() // The Input takes over from the next frame.
+	}
+}
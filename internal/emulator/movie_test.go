@@ -0,0 +1,112 @@
+package emulator
+
+import (
+	"bytes"
+	"gomeboy/internal/joypad"
+	"gomeboy/internal/movie"
+	"testing"
+)
+
+var movieScript = []byte{
+	joypad.ButtonRight, joypad.ButtonRight, 0, joypad.ButtonLeft | joypad.ButtonUp,
+	joypad.ButtonDown, 0, 0, joypad.ButtonRight | joypad.ButtonDown,
+}
+
+// The recordMovie records frames of emu with the movieScript,
+// and returns the movie (through its file format) and the screen hash at the end.
+func recordMovie(t *testing.T, emu *Emulator, frames int) (*movie.Movie, uint32) {
+	t.Helper()
+	m, err := emu.StartRecording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu.Input = &scriptedInput{script: movieScript}
+	runFrames(t, emu, frames)
+	emu.StopMovie()
+
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := movie.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded, getScreenHash(emu)
+}
+
+// The playMovie plays m on a new Emulator, and returns the screen hash at the end.
+// The Input of the player is ignored while playing.
+func playMovie(t *testing.T, m *movie.Movie, frames int) (*Emulator, uint32) {
+	t.Helper()
+	emu := newTestEmulator(t, inputProgram)
+	if err := emu.PlayMovie(m); err != nil {
+		t.Fatal(err)
+	}
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonA | joypad.ButtonLeft}}
+	for i := 0; i < frames; i++ {
+		if !emu.IsPlayingMovie() {
+			t.Fatalf("playback ended at frame %d of %d", i, frames)
+		}
+		if emu.RunFrame() == -1 {
+			t.Fatalf("CPU panicked at frame %d", i+1)
+		}
+	}
+	if emu.IsPlayingMovie() {
+		t.Error("playback did not end with the last frame")
+	}
+	return emu, getScreenHash(emu)
+}
+
+func TestMovieFromPowerOn(t *testing.T) {
+	const frames = 40
+	rec := newTestEmulator(t, inputProgram)
+	m, want := recordMovie(t, rec, frames)
+	if !m.IsFromPowerOn() || len(m.Frames) != frames {
+		t.Fatalf("IsFromPowerOn = %v, frames = %d, want true, %d", m.IsFromPowerOn(), len(m.Frames), frames)
+	}
+
+	play, got := playMovie(t, m, frames)
+	if got != want {
+		t.Errorf("screen hash = %08X, want %08X", got, want)
+	}
+	if !bytes.Equal(saveState(t, play), saveState(t, rec)) {
+		t.Error("the machine state after playback differs from the recording")
+	}
+}
+
+// The recording starts while a button is held, so the first frame of the playback
+// must see the same press edge (and joypad interrupt) as the recording.
+func TestMovieFromState(t *testing.T) {
+	const frames = 40
+	rec := newTestEmulator(t, inputProgram)
+	rec.Input = &scriptedInput{script: []byte{joypad.ButtonRight}}
+	runFrames(t, rec, 15)
+	m, want := recordMovie(t, rec, frames)
+	if m.IsFromPowerOn() {
+		t.Fatal("the movie does not start from a state")
+	}
+
+	play, got := playMovie(t, m, frames)
+	if got != want {
+		t.Errorf("screen hash = %08X, want %08X", got, want)
+	}
+	if !bytes.Equal(saveState(t, play), saveState(t, rec)) {
+		t.Error("the machine state after playback differs from the recording")
+	}
+}
+
+func TestPlayMovieRejects(t *testing.T) {
+	rec := newTestEmulator(t, inputProgram)
+	m, _ := recordMovie(t, rec, 5)
+
+	other := newTestEmulator(t, append(bytes.Clone(inputProgram), 0x76))
+	if err := other.PlayMovie(m); err != ErrMovieROMMismatch {
+		t.Errorf("other ROM: err = %v, want ErrMovieROMMismatch", err)
+	}
+	started := newTestEmulator(t, inputProgram)
+	runFrames(t, started, 1)
+	if err := started.PlayMovie(m); err != ErrMovieNotAtPowerOn {
+		t.Errorf("after running: err = %v, want ErrMovieNotAtPowerOn", err)
+	}
+}
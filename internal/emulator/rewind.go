@@ -0,0 +1,40 @@
+package emulator
+
+import (
+	"bytes"
+	"gomeboy/internal/rewind"
+)
+
+// The EnableRewind starts taking a snapshot every interval frames in RunFrame,
+// using up to budget bytes. (See rewind.DefaultBudget and rewind.DefaultInterval)
+func (e *Emulator) EnableRewind(budget, interval int) {
+	e.rewind = rewind.NewBuffer(budget, max(interval, 1))
+}
+
+func (e *Emulator) captureRewind() {
+	var buf bytes.Buffer
+	if err := e.SaveState(&buf); err != nil {
+		return
+	}
+	e.rewind.Push(buf.Bytes())
+}
+
+// The RewindFrame goes back to the previous snapshot, and runs a frame from it to draw the screen.
+// Each call goes back by the interval, so calling it every interval frames rewinds in real time.
+// It returns false if there is nothing to rewind.
+func (e *Emulator) RewindFrame() bool {
+	if e.rewind == nil {
+		return false
+	}
+	state := e.rewind.Pop()
+	if state == nil {
+		return false
+	}
+	e.restoreState(state)
+	e.StopMovie()
+	// The frame is run only to draw the screen, so its sound is not played.
+	e.CPU.Bus.APU.IsMuted = true
+	e.runFrame()
+	e.CPU.Bus.APU.IsMuted = false
+	return true
+}
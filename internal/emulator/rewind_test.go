@@ -0,0 +1,47 @@
+package emulator
+
+import (
+	"bytes"
+	"gomeboy/internal/joypad"
+	"testing"
+)
+
+func TestRewindFrame(t *testing.T) {
+	emu := newTestEmulator(t, inputProgram)
+	emu.EnableRewind(1<<20, 1)
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonRight}}
+	runFrames(t, emu, 11)
+	want := saveState(t, emu)
+	runFrames(t, emu, 4)
+
+	// Each RewindFrame restores a snapshot and runs a frame from it,
+	// so the 6th one restores the snapshot of frame 10 and runs frame 11.
+	for i := 0; i < 6; i++ {
+		if !emu.RewindFrame() {
+			t.Fatalf("nothing to rewind at %d", i)
+		}
+	}
+	if !bytes.Equal(saveState(t, emu), want) {
+		t.Error("the state after rewinding differs from the state at that frame")
+	}
+}
+
+// Loading a state forgets the snapshots taken before it.
+func TestLoadStateClearsRewind(t *testing.T) {
+	emu := newTestEmulator(t, inputProgram)
+	emu.EnableRewind(1<<20, 1)
+	runFrames(t, emu, 5)
+	state := saveState(t, emu)
+	runFrames(t, emu, 5)
+
+	if err := emu.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	if emu.RewindFrame() {
+		t.Error("rewound into the timeline before loading the state")
+	}
+	runFrames(t, emu, 1)
+	if !emu.RewindFrame() {
+		t.Error("the snapshots after loading are not kept")
+	}
+}
@@ -0,0 +1,32 @@
+package emulator
+
+// The RumbleEvent is a change of the rumble motor of the cartridge.
+type RumbleEvent struct {
+	Frame int
+	IsOn  bool
+}
+
+// The RumbleLog records the rumble motor changes instead of vibrating a gamepad.
+// (for headless runs and tests)
+type RumbleLog struct {
+	Events []RumbleEvent
+}
+
+// The NewRumbleLog starts recording the rumble of e.
+// It returns nil if the cartridge has no rumble motor.
+func NewRumbleLog(e *Emulator) *RumbleLog {
+	l := &RumbleLog{}
+	ok := e.SetRumbleHandler(func(isOn bool) {
+		l.Events = append(l.Events, RumbleEvent{Frame: e.FrameCount, IsOn: isOn})
+	})
+	if !ok {
+		return nil
+	}
+	return l
+}
+
+// The SetRumbleHandler sets the function called when the rumble motor is turned on or off.
+// It returns false if the cartridge has no rumble motor.
+func (e *Emulator) SetRumbleHandler(fn func(isOn bool)) bool {
+	return e.CPU.Bus.Memory.SetRumbleHandler(fn)
+}
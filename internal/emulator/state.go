@@ -0,0 +1,99 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gomeboy/internal/savestate"
+	"io"
+)
+
+// StateVersion must be incremented whenever the layout of the saved state changes.
+const StateVersion uint32 = 10
+
+var stateMagic = [4]byte{'G', 'M', 'B', 'S'}
+
+var (
+	ErrNotSaveState     = errors.New("not a GOmeBoy save state")
+	ErrStateROMMismatch = errors.New("save state was made with a different ROM")
+)
+
+// The StateVersionError is returned when a save state was made by another version of the format.
+type StateVersionError struct {
+	Version uint32
+}
+
+func (err *StateVersionError) Error() string {
+	return fmt.Sprintf("unsupported save state version %d (expected %d)", err.Version, StateVersion)
+}
+
+// The SaveState writes the whole machine state
+// (CPU, Bus, Memory, MBC, PPU, Timer, Joypad, Serial, APU) to w.
+func (e *Emulator) SaveState(w io.Writer) error {
+	enc := savestate.NewEncoder(w)
+	enc.Write(stateMagic)
+	enc.Write(StateVersion)
+	enc.Write(e.romChecksum)
+
+	enc.Write(e.cpuCycles)
+	e.CPU.SaveState(enc)
+	e.CPU.Bus.SaveState(enc)
+	return enc.Err()
+}
+
+// The LoadState restores a state written by SaveState.
+// If the state is invalid, the machine is left as it was.
+func (e *Emulator) LoadState(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	src := bytes.NewReader(data)
+	dec := savestate.NewDecoder(src)
+
+	var magic [4]byte
+	var version, romChecksum uint32
+	dec.Read(&magic)
+	dec.Read(&version)
+	dec.Read(&romChecksum)
+	switch {
+	case dec.Err() != nil || magic != stateMagic:
+		return ErrNotSaveState
+	case version != StateVersion:
+		return &StateVersionError{Version: version}
+	case romChecksum != e.romChecksum:
+		return ErrStateROMMismatch
+	}
+
+	// Keep the current state to roll back if the data turns out to be broken.
+	var backup bytes.Buffer
+	if err := e.SaveState(&backup); err != nil {
+		return err
+	}
+
+	dec.Read(&e.cpuCycles)
+	e.CPU.LoadState(dec)
+	e.CPU.Bus.LoadState(dec)
+	err = dec.Err()
+	if err == nil && src.Len() != 0 {
+		err = ErrNotSaveState
+	}
+	if err != nil {
+		e.restoreState(backup.Bytes())
+		return fmt.Errorf("broken save state: %w", err)
+	}
+	e.StopMovie()
+	if e.rewind != nil {
+		e.rewind.Clear() // The snapshots are of the timeline before loading.
+	}
+	e.isPowerOn = false
+	return nil
+}
+
+// The restoreState loads a state that this Emulator has just saved itself.
+func (e *Emulator) restoreState(data []byte) {
+	dec := savestate.NewDecoder(bytes.NewReader(data[12:])) // Skip the header.
+	dec.Read(&e.cpuCycles)
+	e.CPU.LoadState(dec)
+	e.CPU.Bus.LoadState(dec)
+}
@@ -0,0 +1,168 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"gomeboy/internal/joypad"
+	"gomeboy/internal/savestate"
+	"testing"
+)
+
+func saveState(t *testing.T, emu *Emulator) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := emu.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	emu := newTestEmulator(t, inputProgram)
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonRight, joypad.ButtonRight | joypad.ButtonUp, 0}}
+	runFrames(t, emu, 20)
+	emu.Input = &scriptedInput{script: []byte{joypad.ButtonLeft}} // Held while saving
+	runFrames(t, emu, 1)
+	state := saveState(t, emu)
+
+	loaded := newTestEmulator(t, inputProgram)
+	if err := loaded.LoadState(bytes.NewReader(state)); err != nil {
+		t.Fatal(err)
+	}
+	if got := saveState(t, loaded); !bytes.Equal(got, state) {
+		t.Fatal("the state saved after loading differs from the loaded state")
+	}
+
+	// The loaded machine must continue exactly as the original one.
+	loaded.Input = &scriptedInput{script: []byte{joypad.ButtonLeft}}
+	runFrames(t, emu, 10)
+	runFrames(t, loaded, 10)
+	if !bytes.Equal(saveState(t, loaded), saveState(t, emu)) {
+		t.Error("the loaded machine diverged from the original one")
+	}
+}
+
+// The ppuStateOffset returns where the PPU starts in a state saved by emu
+// (after the header, the CPU, the Bus fields and the memory).
+func ppuStateOffset(t *testing.T, emu *Emulator) int {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := savestate.NewEncoder(&buf)
+	enc.Write(emu.cpuCycles)
+	emu.CPU.SaveState(enc)
+	emu.CPU.Bus.Memory.SaveState(enc)
+	if enc.Err() != nil {
+		t.Fatal(enc.Err())
+	}
+	const headerSize, busSize = 12, 12
+	return headerSize + busSize + buf.Len()
+}
+
+func TestLoadStateRejectsInvalidData(t *testing.T) {
+	src := newTestEmulator(t, inputProgram)
+	runFrames(t, src, 5)
+	state := saveState(t, src)
+	// VRAM, OAM, 18 registers, the previous LCDC, WLY, then the dots.
+	dotsOffset := ppuStateOffset(t, src) + 0x4000 + 160 + 18 + 1 + 8
+
+	isRangeError := func(name string) func(err error) bool {
+		return func(err error) bool {
+			var rerr *savestate.RangeError
+			return errors.As(err, &rerr) && rerr.Name == name
+		}
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(e *Emulator) // Changes the machine before saving
+		modify  func(b []byte) []byte
+		check   func(err error) bool
+	}{
+		{
+			name:   "wrong magic",
+			modify: func(b []byte) []byte { b[0] = 'X'; return b },
+			check:  func(err error) bool { return errors.Is(err, ErrNotSaveState) },
+		},
+		{
+			name: "wrong version",
+			modify: func(b []byte) []byte {
+				binary.LittleEndian.PutUint32(b[4:], StateVersion+1)
+				return b
+			},
+			check: func(err error) bool {
+				var verr *StateVersionError
+				return errors.As(err, &verr) && verr.Version == StateVersion+1
+			},
+		},
+		{
+			name:   "wrong ROM CRC",
+			modify: func(b []byte) []byte { b[8] ^= 0xFF; return b },
+			check:  func(err error) bool { return errors.Is(err, ErrStateROMMismatch) },
+		},
+		{
+			name:   "truncated",
+			modify: func(b []byte) []byte { return b[:len(b)/2] },
+			check:  func(err error) bool { return err != nil },
+		},
+		{
+			name:   "trailing data",
+			modify: func(b []byte) []byte { return append(b, 0) },
+			check:  func(err error) bool { return errors.Is(err, ErrNotSaveState) },
+		},
+		{
+			name: "OAM DMA index",
+			prepare: func(e *Emulator) {
+				e.CPU.Bus.IsDMATransferInProgress = true
+				e.CPU.Bus.DMATransferIndex = 160
+			},
+			check: isRangeError("OAM DMA index"),
+		},
+		{
+			name: "OAM DMA source",
+			prepare: func(e *Emulator) {
+				e.CPU.Bus.IsDMATransferInProgress = true
+				e.CPU.Bus.PPU.SetDMA(0xE0)
+			},
+			check: isRangeError("OAM DMA source"),
+		},
+		{
+			name:    "HDMA length",
+			prepare: func(e *Emulator) { e.CPU.Bus.PPU.VDMALen = 0x810 },
+			check:   isRangeError("HDMA length"),
+		},
+		{
+			name: "PPU dots",
+			modify: func(b []byte) []byte {
+				binary.LittleEndian.PutUint64(b[dotsOffset:], 456)
+				return b
+			},
+			check: isRangeError("PPU dots"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emu := newTestEmulator(t, inputProgram)
+			runFrames(t, emu, 3)
+			before := saveState(t, emu)
+
+			data := bytes.Clone(state)
+			if tt.prepare != nil {
+				e := newTestEmulator(t, inputProgram)
+				runFrames(t, e, 5)
+				tt.prepare(e)
+				data = saveState(t, e)
+			}
+			if tt.modify != nil {
+				data = tt.modify(data)
+			}
+			err := emu.LoadState(bytes.NewReader(data))
+			if !tt.check(err) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(saveState(t, emu), before) {
+				t.Error("the machine was changed by the rejected state")
+			}
+		})
+	}
+}
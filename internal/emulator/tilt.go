@@ -0,0 +1,8 @@
+package emulator
+
+// The SetTilt sets the tilt of the cartridge in G.
+// (x > 0: tilted to the right, y > 0: tilted toward the player, 0: level)
+// It returns false if the cartridge has no accelerometer.
+func (e *Emulator) SetTilt(x, y float64) bool {
+	return e.CPU.Bus.Memory.SetAcceleration(x, y)
+}
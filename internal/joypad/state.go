@@ -0,0 +1,17 @@
+package joypad
+
+import "gomeboy/internal/savestate"
+
+// The buttons are saved too, so the first SetButtons after loading
+// sees the same press edges as when the state was saved.
+func (j *Joypad) SaveState(e *savestate.Encoder) {
+	e.Write(j.sel)
+	e.Write(j.buttons)
+	e.Write(j.HasIRQ)
+}
+
+func (j *Joypad) LoadState(d *savestate.Decoder) {
+	d.Read(&j.sel)
+	d.Read(&j.buttons)
+	d.Read(&j.HasIRQ)
+}
@@ -0,0 +1,246 @@
+package mbc
+
+import (
+	"gomeboy/internal/camera"
+	"gomeboy/internal/savestate"
+)
+
+// Camera registers (A000~A035 when the RAM bank is 0x10~)
+const (
+	camCapture    = 0x00 // bit0: Start/Busy, bit1-2: Filter mode
+	camGain       = 0x01 // bit0-4: Gain, bit5-7: Edge enhancement mode
+	camExposureHi = 0x02
+	camExposureLo = 0x03
+	camEdge       = 0x04 // bit0-2: Output voltage, bit3: Invert, bit4-6: Edge enhancement ratio
+	camMatrix     = 0x06 // 4x4 matrix of 3 thresholds (A006~A035)
+	camRegsSize   = 0x36
+)
+
+// The captured image is written to RAM bank 0 at A100 (16x14 tiles).
+const CameraImageAddr = 0x0100
+
+// The PocketCamera is the mapper of the Game Boy Camera (Pocket Camera).
+// It has 128KiB RAM, and the registers of the sensor are mapped instead of RAM
+// when bit 4 of the RAM bank is set.
+type PocketCamera struct {
+	dirtyFlag
+
+	rom           []byte // =.gb data
+	eram          []byte // =External RAM, SRAM
+	romBank       byte
+	ramBank       byte // bit4: Registers
+	isRAMEnable   bool // Only for writing
+	totalRAMBanks int
+
+	regs          [camRegsSize]byte
+	captureCycles int // Remaining CPU cycles of the capture
+	frame         *camera.Frame
+	source        camera.Source
+}
+
+func NewPocketCamera(rom, sav []byte, TotalRAMBanks int) *PocketCamera {
+	cam := &PocketCamera{
+		rom:           rom,
+		romBank:       1,
+		totalRAMBanks: TotalRAMBanks,
+		source:        camera.NewTestPattern(),
+	}
+	cam.eram = make([]byte, TotalRAMBanks*0x2000)
+	if len(sav) <= len(cam.eram) {
+		copy(cam.eram, sav)
+	}
+	return cam
+}
+
+// The SetImageSource sets the image seen by the sensor (the default is the test pattern).
+func (cam *PocketCamera) SetImageSource(src camera.Source) {
+	cam.source = src
+}
+
+// Read from ROM in the current bank
+func (cam *PocketCamera) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return cam.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 00 ~ 3F
+		offset := 0x4000*uint32(cam.romBank) + uint32(addr-0x4000)
+		return cam.rom[offset%uint32(len(cam.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+// RAM can be read without enabling it.
+// Only A000 of the registers can be read, others read 0.
+func (cam *PocketCamera) ReadERAM(addr uint16) byte {
+	if cam.ramBank&0x10 != 0 {
+		if addr&0x7F == camCapture {
+			return cam.regs[camCapture] & 0x07
+		}
+		return 0x00
+	}
+	if cam.captureCycles > 0 || cam.totalRAMBanks == 0 { // RAM is not accessible while capturing.
+		return 0x00
+	}
+	bank := int(cam.ramBank) % cam.totalRAMBanks
+	return cam.eram[bank*0x2000+int(addr)-0xA000]
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+func (cam *PocketCamera) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // RAM Enable
+		cam.isRAMEnable = val&0x0F == 0x0A
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		cam.romBank = val & 0x3F
+	case addr >= 0x4000 && addr < 0x6000: // RAM Bank Number or Registers
+		cam.ramBank = val & 0x1F
+	}
+}
+
+// Registers are mirrored every 0x80 bytes.
+func (cam *PocketCamera) WriteERAM(addr uint16, val byte) {
+	if cam.ramBank&0x10 != 0 {
+		reg := int(addr & 0x7F)
+		if reg >= camRegsSize {
+			return
+		}
+		if reg == camCapture {
+			val &= 0x07
+			if val&0x01 != 0 && cam.captureCycles == 0 {
+				cam.startCapture()
+			}
+			if val&0x01 == 0 { // Writing 0 cancels the capture.
+				cam.captureCycles = 0
+			}
+		}
+		cam.regs[reg] = val
+		return
+	}
+	if !cam.isRAMEnable || cam.captureCycles > 0 || cam.totalRAMBanks == 0 {
+		return
+	}
+	bank := int(cam.ramBank) % cam.totalRAMBanks
+	cam.eram[bank*0x2000+int(addr)-0xA000] = val
+	cam.isDirty = true
+}
+
+// The capture time depends on the exposure. (in 1MHz cycles: 32446 + 512 if N is not set + 16 x exposure)
+func (cam *PocketCamera) startCapture() {
+	cycles := 32446 + 16*cam.getExposure()
+	if cam.regs[camGain]&0x80 == 0 {
+		cycles += 512
+	}
+	cam.captureCycles = cycles * 4
+	cam.frame = camera.Grab(cam.source)
+}
+
+func (cam *PocketCamera) getExposure() int {
+	return int(cam.regs[camExposureHi])<<8 | int(cam.regs[camExposureLo])
+}
+
+// The Step advances the capture, and writes the image to RAM when it finishes.
+func (cam *PocketCamera) Step(cycles int) {
+	if cam.captureCycles == 0 {
+		return
+	}
+	cam.captureCycles -= cycles
+	if cam.captureCycles <= 0 {
+		cam.captureCycles = 0
+		cam.regs[camCapture] &^= 0x01
+		cam.writeImage()
+	}
+}
+
+// The writeImage processes the captured frame (exposure, gain, edge enhancement)
+// and converts it to tiles with the dithering matrix.
+func (cam *PocketCamera) writeImage() {
+	if cam.totalRAMBanks == 0 || cam.frame == nil {
+		return
+	}
+	var tiles [16 * 14 * 16]byte
+	for y := range camera.SensorHeight {
+		for x := range camera.SensorWidth {
+			v := cam.getProcessedPixel(x, y)
+			if cam.regs[camGain]&0xE0 == 0xE0 { // 2D edge enhancement
+				ratio := edgeRatios[cam.regs[camEdge]>>4&0x07]
+				v += 4 * v * ratio
+				v -= ratio * (cam.getProcessedPixel(x-1, y) + cam.getProcessedPixel(x+1, y) +
+					cam.getProcessedPixel(x, y-1) + cam.getProcessedPixel(x, y+1))
+			}
+			if cam.regs[camEdge]&0x08 != 0 {
+				v = 255 - v
+			}
+
+			// Dithering: 3 thresholds for the position in the 4x4 matrix
+			m := camMatrix + ((y%4)*4+x%4)*3
+			color := byte(0)
+			switch {
+			case v < float64(cam.regs[m]):
+				color = 3
+			case v < float64(cam.regs[m+1]):
+				color = 2
+			case v < float64(cam.regs[m+2]):
+				color = 1
+			}
+
+			i := ((y/8)*16+x/8)*16 + (y%8)*2
+			bit := byte(0x80 >> (x % 8))
+			if color&0x01 != 0 {
+				tiles[i] |= bit
+			}
+			if color&0x02 != 0 {
+				tiles[i+1] |= bit
+			}
+		}
+	}
+	// The image is not marked as a change of the save data.
+	// (It is rewritten by every capture in the viewfinder, and the game copies it to keep it.)
+	copy(cam.eram[CameraImageAddr:], tiles[:])
+}
+
+// Edge enhancement ratios selected by bit 4-6 of A004
+var edgeRatios = [8]float64{0.5, 0.75, 1, 1.25, 2, 3, 4, 5}
+
+// The brightness of a sensor pixel after the exposure and the gain.
+// (Pixels outside of the image are clamped to the edge.)
+func (cam *PocketCamera) getProcessedPixel(x, y int) float64 {
+	x = max(0, min(camera.SensorWidth-1, x))
+	y = max(0, min(camera.SensorHeight-1, y))
+	gain := 1 + float64(cam.regs[camGain]&0x1F)/8
+	return float64(cam.frame[y][x]) * gain * float64(cam.getExposure()) / 0x1000
+}
+
+func (cam *PocketCamera) GetSaveData() []byte {
+	return cam.eram
+}
+
+// The frame being captured is saved during a capture,
+// so loading a state does not grab another image from the source.
+func (cam *PocketCamera) SaveState(e *savestate.Encoder) {
+	e.Write(cam.eram)
+	e.Write(cam.romBank)
+	e.Write(cam.ramBank)
+	e.Write(cam.isRAMEnable)
+	e.Write(&cam.regs)
+	e.WriteInt(cam.captureCycles)
+	if cam.captureCycles > 0 {
+		e.Write(cam.frame)
+	}
+}
+
+func (cam *PocketCamera) LoadState(d *savestate.Decoder) {
+	d.Read(cam.eram)
+	d.Read(&cam.romBank)
+	d.Read(&cam.ramBank)
+	d.Read(&cam.isRAMEnable)
+	d.Check(cam.romBank <= 0x3F && cam.ramBank <= 0x1F, "camera bank")
+	d.Read(&cam.regs)
+	d.ReadInt(&cam.captureCycles)
+	if cam.captureCycles > 0 {
+		cam.frame = &camera.Frame{}
+		d.Read(cam.frame)
+	}
+}
@@ -0,0 +1,92 @@
+package mbc
+
+import (
+	"bytes"
+	"gomeboy/internal/camera"
+	"gomeboy/internal/savestate"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// The countingSource returns a brighter image each time it is captured.
+type countingSource struct {
+	count int
+}
+
+func (s *countingSource) Capture() image.Image {
+	s.count++
+	img := image.NewGray(image.Rect(0, 0, camera.SensorWidth, camera.SensorHeight))
+	for i := range img.Pix {
+		img.Pix[i] = byte(s.count * 40)
+	}
+	img.Set(0, 0, color.Gray{})
+	return img
+}
+
+func newTestCamera(t *testing.T) (*PocketCamera, *countingSource) {
+	t.Helper()
+	cam := NewPocketCamera(newBankedROM(4), nil, 16)
+	src := &countingSource{}
+	cam.SetImageSource(src)
+	cam.WriteROM(0x4000, 0x10)  // Registers
+	cam.WriteERAM(0xA002, 0x10) // Exposure
+	for i := camMatrix; i < camRegsSize; i += 3 {
+		cam.WriteERAM(0xA000+uint16(i), 0x40)
+		cam.WriteERAM(0xA000+uint16(i+1), 0x80)
+		cam.WriteERAM(0xA000+uint16(i+2), 0xC0)
+	}
+	return cam, src
+}
+
+func finishCapture(cam *PocketCamera) []byte {
+	for cam.captureCycles > 0 {
+		cam.Step(4)
+	}
+	return bytes.Clone(cam.eram[CameraImageAddr : CameraImageAddr+16*14*16])
+}
+
+// Loading a state during a capture restores the frame, instead of grabbing another one.
+func TestPocketCameraLoadStateKeepsFrame(t *testing.T) {
+	cam, src := newTestCamera(t)
+	cam.WriteERAM(0xA000, 0x01) // Start the capture.
+
+	var buf bytes.Buffer
+	e := savestate.NewEncoder(&buf)
+	cam.SaveState(e)
+	if e.Err() != nil {
+		t.Fatal(e.Err())
+	}
+	want := finishCapture(cam)
+
+	for i := 0; i < 3; i++ {
+		d := savestate.NewDecoder(bytes.NewReader(buf.Bytes()))
+		cam.LoadState(d)
+		if d.Err() != nil {
+			t.Fatal(d.Err())
+		}
+	}
+	if src.count != 1 {
+		t.Errorf("source captured %d times, want 1", src.count)
+	}
+	if got := finishCapture(cam); !bytes.Equal(got, want) {
+		t.Error("the image after loading the state differs")
+	}
+}
+
+// Only the writes by the CPU mark the save data as changed.
+func TestPocketCameraCaptureIsNotDirty(t *testing.T) {
+	cam, _ := newTestCamera(t)
+	cam.WriteERAM(0xA000, 0x01)
+	finishCapture(cam)
+	if cam.IsDirty() {
+		t.Error("a capture marked the save data as changed")
+	}
+
+	cam.WriteROM(0x0000, 0x0A)
+	cam.WriteROM(0x4000, 0x00)
+	cam.WriteERAM(0xA000, 0x12)
+	if !cam.IsDirty() {
+		t.Error("a write to RAM did not mark the save data as changed")
+	}
+}
@@ -0,0 +1,60 @@
+package mbc
+
+import "bytes"
+
+// The Nintendo logo in the cartridge header (0104~0133)
+var NintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// Names of the mappers for overriding the cartridge header (config.toml, -mapper)
+var MapperNames = map[string]int{
+	"mbc0":       0,
+	"mbc1":       1,
+	"mbc2":       2,
+	"mbc3":       3,
+	"mbc5":       5,
+	"mbc7":       7,
+	"huc1":       TypeHuC1,
+	"huc3":       TypeHuC3,
+	"mmm01":      TypeMMM01,
+	"camera":     TypeCamera,
+	"wisdomtree": TypeWisdomTree,
+	"m161":       TypeM161,
+	"sachen":     TypeSachen,
+}
+
+// The DetectMapper returns the mapper of the cartridge.
+// Some cartridges (multi-game and unlicensed ones) have a header that lies about the mapper,
+// so they are detected by the heuristics used by other emulators.
+// It returns -1 if the mapper is not supported.
+func DetectMapper(rom []byte) int {
+	cartType := rom[0x0147]
+	switch {
+	// MMM01: the header of the menu is in the last 32KiB (the first header is of a game).
+	case len(rom) >= 0x10000 && isMMM01Header(rom[len(rom)-0x8000:]):
+		return TypeMMM01
+
+	// Wisdom Tree: "ROM ONLY" with a larger ROM, and the publisher name in the ROM.
+	case cartType == 0x00 && len(rom) > 0x8000 &&
+		(bytes.Contains(rom, []byte("WISDOM TREE")) || bytes.Contains(rom, []byte("WISDOM\x00TREE"))):
+		return TypeWisdomTree
+
+	// M161: only "Mani 4 in 1 Tetris Set" is known, and the header says MBC3.
+	case len(rom) == 0x40000 && string(rom[0x0134:0x013E]) == "TETRIS SET":
+		return TypeM161
+
+	// Sachen: the logo at 0104 is replaced, and the real logo is at 0184.
+	// (The locked mapper shows it to the boot ROM by scrambling the address.)
+	case len(rom) >= 0x10000 && !bytes.Equal(rom[0x0104:0x0134], NintendoLogo) &&
+		bytes.Equal(rom[0x0184:0x01B4], NintendoLogo):
+		return TypeSachen
+	}
+	return MBCTypeList[cartType]
+}
+
+func isMMM01Header(bank []byte) bool {
+	return bytes.Equal(bank[0x0104:0x0134], NintendoLogo) && bank[0x0147] >= 0x0B && bank[0x0147] <= 0x0D
+}
@@ -0,0 +1,156 @@
+package mbc
+
+import "testing"
+
+// The newHeaderROM returns a ROM with the logo and the cartridge type in the header.
+func newHeaderROM(size int, cartType byte) []byte {
+	rom := make([]byte, size)
+	copy(rom[0x0104:], NintendoLogo)
+	rom[0x0147] = cartType
+	return rom
+}
+
+func TestDetectMapper(t *testing.T) {
+	InitLists()
+	tests := []struct {
+		name string
+		rom  func() []byte
+		want int
+	}{
+		{
+			name: "MBC1",
+			rom:  func() []byte { return newHeaderROM(0x40000, 0x01) },
+			want: 1,
+		},
+		{
+			name: "ROM only",
+			rom:  func() []byte { return newHeaderROM(0x8000, 0x00) },
+			want: 0,
+		},
+		{
+			name: "unsupported",
+			rom:  func() []byte { return newHeaderROM(0x8000, 0x20) }, // MBC6
+			want: -1,
+		},
+		{
+			name: "MMM01 menu in the last 32KiB",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x01)
+				copy(rom[0x18104:], NintendoLogo)
+				rom[0x18147] = 0x0D
+				return rom
+			},
+			want: TypeMMM01,
+		},
+		{
+			name: "MMM01 type without the logo",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x01)
+				rom[0x18147] = 0x0B
+				return rom
+			},
+			want: 1,
+		},
+		{
+			name: "MBC1 header in the last 32KiB",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x01)
+				copy(rom[0x18104:], NintendoLogo)
+				rom[0x18147] = 0x01
+				return rom
+			},
+			want: 1,
+		},
+		{
+			name: "Wisdom Tree",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x00)
+				copy(rom[0x0150:], "(C) WISDOM TREE")
+				return rom
+			},
+			want: TypeWisdomTree,
+		},
+		{
+			name: "Wisdom Tree with NUL",
+			rom: func() []byte {
+				rom := newHeaderROM(0x10000, 0x00)
+				copy(rom[0x8000:], "WISDOM\x00TREE")
+				return rom
+			},
+			want: TypeWisdomTree,
+		},
+		{
+			name: "Wisdom Tree name in a 32KiB ROM",
+			rom: func() []byte {
+				rom := newHeaderROM(0x8000, 0x00)
+				copy(rom[0x0150:], "WISDOM TREE")
+				return rom
+			},
+			want: 0,
+		},
+		{
+			name: "Wisdom Tree name in an MBC1 ROM",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x01)
+				copy(rom[0x0150:], "WISDOM TREE")
+				return rom
+			},
+			want: 1,
+		},
+		{
+			name: "M161",
+			rom: func() []byte {
+				rom := newHeaderROM(0x40000, 0x10)
+				copy(rom[0x0134:], "TETRIS SET")
+				return rom
+			},
+			want: TypeM161,
+		},
+		{
+			name: "M161 title in another size",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x10)
+				copy(rom[0x0134:], "TETRIS SET")
+				return rom
+			},
+			want: 3,
+		},
+		{
+			name: "Sachen",
+			rom: func() []byte {
+				rom := make([]byte, 0x20000)
+				copy(rom[0x0184:], NintendoLogo)
+				return rom
+			},
+			want: TypeSachen,
+		},
+		{
+			name: "logo at 0104 and 0184",
+			rom: func() []byte {
+				rom := newHeaderROM(0x20000, 0x01)
+				copy(rom[0x0184:], NintendoLogo)
+				return rom
+			},
+			want: 1,
+		},
+		{
+			name: "no logo",
+			rom:  func() []byte { return make([]byte, 0x20000) },
+			want: 0,
+		},
+		{
+			name: "Sachen logo in a 32KiB ROM",
+			rom: func() []byte {
+				rom := make([]byte, 0x8000)
+				copy(rom[0x0184:], NintendoLogo)
+				return rom
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		if got := DetectMapper(tt.rom()); got != tt.want {
+			t.Errorf("%s: got %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+// The value of the IR register when no light is received
+const IRNoLight = 0xC0
+
+// The HuC1 is the Hudson mapper with an infrared port (HuC1+RAM+BT).
+type HuC1 struct {
+	dirtyFlag
+
+	rom           []byte // =.gb data
+	eram          []byte // =External RAM, SRAM
+	romBank       byte
+	ramBank       byte
+	isIRMode      bool // 0000~1FFF = 0x0E selects the IR register instead of RAM.
+	isIRLEDOn     bool
+	totalRAMBanks int
+}
+
+func NewHuC1(rom, sav []byte, TotalRAMBanks int) *HuC1 {
+	huc1 := &HuC1{
+		rom:           rom,
+		romBank:       1,
+		totalRAMBanks: TotalRAMBanks,
+	}
+	huc1.eram = make([]byte, TotalRAMBanks*0x2000)
+	if len(sav) <= len(huc1.eram) {
+		copy(huc1.eram, sav)
+	}
+	return huc1
+}
+
+// Read from ROM in the current bank
+func (huc1 *HuC1) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return huc1.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 00 ~ 3F
+		offset := 0x4000*uint32(huc1.romBank) + uint32(addr-0x4000)
+		return huc1.rom[offset%uint32(len(huc1.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+// Read from eram in the current bank, or the IR register
+// (RAM is always readable, there is no RAM enable.)
+func (huc1 *HuC1) ReadERAM(addr uint16) byte {
+	if huc1.isIRMode {
+		return IRNoLight
+	}
+	if huc1.totalRAMBanks == 0 {
+		return 0xFF
+	}
+	bank := int(huc1.ramBank) % huc1.totalRAMBanks
+	return huc1.eram[bank*0x2000+int(addr)-0xA000]
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+func (huc1 *HuC1) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // RAM or IR select
+		huc1.isIRMode = val&0x0F == 0x0E
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		huc1.romBank = val & 0x3F
+	case addr >= 0x4000 && addr < 0x6000: // RAM Bank Number
+		huc1.ramBank = val & 0x03
+	}
+}
+
+// Write to eram in the current bank, or turn on/off the IR LED
+func (huc1 *HuC1) WriteERAM(addr uint16, val byte) {
+	if huc1.isIRMode {
+		huc1.isIRLEDOn = val&0x01 != 0
+		return
+	}
+	if huc1.totalRAMBanks == 0 {
+		return
+	}
+	bank := int(huc1.ramBank) % huc1.totalRAMBanks
+	huc1.eram[bank*0x2000+int(addr)-0xA000] = val
+	huc1.isDirty = true
+}
+
+func (huc1 *HuC1) GetSaveData() []byte {
+	return huc1.eram
+}
+
+func (huc1 *HuC1) SaveState(e *savestate.Encoder) {
+	e.Write(huc1.eram)
+	e.Write(huc1.romBank)
+	e.Write(huc1.ramBank)
+	e.Write(huc1.isIRMode)
+	e.Write(huc1.isIRLEDOn)
+}
+
+func (huc1 *HuC1) LoadState(d *savestate.Decoder) {
+	d.Read(huc1.eram)
+	d.Read(&huc1.romBank)
+	d.Read(&huc1.ramBank)
+	d.Read(&huc1.isIRMode)
+	d.Read(&huc1.isIRLEDOn)
+	d.Check(huc1.romBank <= 0x3F && huc1.ramBank <= 0x03, "HuC1 bank")
+}
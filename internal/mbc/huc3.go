@@ -0,0 +1,271 @@
+package mbc
+
+import (
+	"encoding/binary"
+	"gomeboy/internal/savestate"
+	"time"
+)
+
+const (
+	CyclesPerHuC3Minute = 60 * CyclesPerRTCSecond
+	MinutesPerDay       = 24 * 60
+)
+
+// The size of the clock footer appended to the .sav file (SameBoy compatible)
+// UNIX time (uint64), minutes, days, alarm minutes, alarm days (uint16 each), alarm enabled (uint8)
+const HuC3FooterSize = 17
+
+// HuC3 modes (written to 0000~1FFF)
+const (
+	huc3ModeRAMReadOnly = 0x0
+	huc3ModeRAM         = 0xA
+	huc3ModeCommand     = 0xB // Write a clock command to A000
+	huc3ModeResponse    = 0xC // Read the result of the command from A000
+	huc3ModeSemaphore   = 0xD // Reads 1 when the clock is ready
+	huc3ModeIR          = 0xE
+)
+
+// The HuC3 is the Hudson mapper with a clock (minutes and days) and an infrared port.
+// The clock is not mapped to registers like the MBC3,
+// but accessed by 4bit commands through A000.
+type HuC3 struct {
+	dirtyFlag
+
+	rom           []byte // =.gb data
+	eram          []byte // =External RAM, SRAM
+	romBank       byte
+	ramBank       byte
+	mode          byte
+	isIRLEDOn     bool
+	totalRAMBanks int
+
+	// Clock
+	minutes      uint16 // 0~1439 (12bit)
+	days         uint16
+	alarmMinutes uint16
+	alarmDays    uint16
+	isAlarmOn    bool
+	accessIndex  byte // Address of the nibble accessed by the commands
+	accessFlags  byte
+	response     byte
+	clockCycles  int // CPU cycles within the current minute
+}
+
+// The NewHuC3 returns a *SaveSizeError if sav has an unknown footer.
+func NewHuC3(rom, sav []byte, TotalRAMBanks int) (*HuC3, error) {
+	huc3 := &HuC3{
+		rom:           rom,
+		romBank:       1,
+		totalRAMBanks: TotalRAMBanks,
+	}
+	huc3.eram = make([]byte, TotalRAMBanks*0x2000)
+	footerSize := len(sav) - len(huc3.eram)
+	switch {
+	case footerSize <= 0:
+		copy(huc3.eram, sav)
+	case footerSize == HuC3FooterSize:
+		copy(huc3.eram, sav)
+		huc3.loadClockFooter(sav[len(huc3.eram):])
+	default:
+		return nil, &SaveSizeError{Size: len(sav), RAMSize: len(huc3.eram)}
+	}
+	return huc3, nil
+}
+
+// Read from ROM in the current bank
+func (huc3 *HuC3) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return huc3.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 00 ~ 7F
+		offset := 0x4000*uint32(huc3.romBank) + uint32(addr-0x4000)
+		return huc3.rom[offset%uint32(len(huc3.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+func (huc3 *HuC3) ReadERAM(addr uint16) byte {
+	switch huc3.mode {
+	case huc3ModeRAMReadOnly, huc3ModeRAM:
+		if huc3.totalRAMBanks == 0 {
+			return 0xFF
+		}
+		bank := int(huc3.ramBank) % huc3.totalRAMBanks
+		return huc3.eram[bank*0x2000+int(addr)-0xA000]
+	case huc3ModeResponse:
+		if huc3.accessFlags == 0x2 {
+			return 1
+		}
+		return huc3.response
+	case huc3ModeSemaphore: // The clock is always ready.
+		return 1
+	case huc3ModeIR:
+		return IRNoLight
+	default:
+		return 0xFF
+	}
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+func (huc3 *HuC3) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // Mode select
+		huc3.mode = val & 0x0F
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		huc3.romBank = val & 0x7F
+	case addr >= 0x4000 && addr < 0x6000: // RAM Bank Number
+		huc3.ramBank = val & 0x03
+	}
+}
+
+func (huc3 *HuC3) WriteERAM(addr uint16, val byte) {
+	switch huc3.mode {
+	case huc3ModeRAM:
+		if huc3.totalRAMBanks == 0 {
+			return
+		}
+		bank := int(huc3.ramBank) % huc3.totalRAMBanks
+		huc3.eram[bank*0x2000+int(addr)-0xA000] = val
+		huc3.isDirty = true
+	case huc3ModeCommand:
+		huc3.command(val>>4&0x07, val&0x0F)
+	case huc3ModeIR:
+		huc3.isIRLEDOn = val&0x01 != 0
+	}
+}
+
+// Commands (upper nibble of the value written to A000)
+//
+//	1: Read the nibble at the access index, then increment the index
+//	2: Write the nibble at the access index
+//	3: Write the nibble at the access index, then increment the index
+//	4: Set the lower nibble of the access index
+//	5: Set the upper nibble of the access index
+//	6: Extended command
+//
+// Index 00~02 are the minutes, 03~06 are the days, and 58~5F are the alarm.
+// Reading other indexes returns 0.
+func (huc3 *HuC3) command(cmd, arg byte) {
+	i := huc3.accessIndex
+	switch cmd {
+	case 1:
+		switch {
+		case i < 3:
+			huc3.response = byte(huc3.minutes>>(i*4)) & 0x0F
+		case i < 7:
+			huc3.response = byte(huc3.days>>((i-3)*4)) & 0x0F
+		default:
+			huc3.response = 0
+		}
+		huc3.accessIndex++
+	case 2, 3:
+		switch {
+		case i < 3:
+			huc3.minutes = setNibble(huc3.minutes, i, arg)
+		case i < 7:
+			huc3.days = setNibble(huc3.days, i-3, arg)
+		case i >= 0x58 && i <= 0x5A:
+			huc3.alarmMinutes = setNibble(huc3.alarmMinutes, i-0x58, arg)
+		case i >= 0x5B && i <= 0x5E:
+			huc3.alarmDays = setNibble(huc3.alarmDays, i-0x5B, arg)
+		case i == 0x5F:
+			huc3.isAlarmOn = arg&0x01 != 0
+		}
+		huc3.isDirty = true
+		if cmd == 3 {
+			huc3.accessIndex++
+		}
+	case 4:
+		huc3.accessIndex = i&0xF0 | arg
+	case 5:
+		huc3.accessIndex = i&0x0F | arg<<4
+	case 6:
+		huc3.accessFlags = arg
+	}
+}
+
+func setNibble(v uint16, n, nibble byte) uint16 {
+	shift := n * 4
+	return v&^(0x0F<<shift) | uint16(nibble)<<shift
+}
+
+// The Step advances the clock by the emulated time.
+func (huc3 *HuC3) Step(cycles int) {
+	huc3.clockCycles += cycles
+	for huc3.clockCycles >= CyclesPerHuC3Minute {
+		huc3.clockCycles -= CyclesPerHuC3Minute
+		huc3.advance(1)
+	}
+}
+
+func (huc3 *HuC3) advance(minutes int64) {
+	total := int64(huc3.minutes) + minutes
+	huc3.days += uint16(total / MinutesPerDay)
+	huc3.minutes = uint16(total % MinutesPerDay)
+}
+
+// The save data is the eram followed by the clock footer.
+func (huc3 *HuC3) GetSaveData() []byte {
+	data := make([]byte, len(huc3.eram), len(huc3.eram)+HuC3FooterSize)
+	copy(data, huc3.eram)
+	return append(data, huc3.makeClockFooter(time.Now())...)
+}
+
+func (huc3 *HuC3) makeClockFooter(now time.Time) []byte {
+	footer := make([]byte, HuC3FooterSize)
+	binary.LittleEndian.PutUint64(footer[0:], uint64(now.Unix()))
+	binary.LittleEndian.PutUint16(footer[8:], huc3.minutes)
+	binary.LittleEndian.PutUint16(footer[10:], huc3.days)
+	binary.LittleEndian.PutUint16(footer[12:], huc3.alarmMinutes)
+	binary.LittleEndian.PutUint16(footer[14:], huc3.alarmDays)
+	if huc3.isAlarmOn {
+		footer[16] = 1
+	}
+	return footer
+}
+
+// The loadClockFooter restores the clock,
+// and advances it by the real time elapsed since the file was saved.
+func (huc3 *HuC3) loadClockFooter(footer []byte) {
+	savedAt := int64(binary.LittleEndian.Uint64(footer[0:]))
+	huc3.minutes = binary.LittleEndian.Uint16(footer[8:]) % MinutesPerDay
+	huc3.days = binary.LittleEndian.Uint16(footer[10:])
+	huc3.alarmMinutes = binary.LittleEndian.Uint16(footer[12:])
+	huc3.alarmDays = binary.LittleEndian.Uint16(footer[14:])
+	huc3.isAlarmOn = footer[16]&0x01 != 0
+	if elapsed := time.Now().Unix() - savedAt; elapsed > 0 {
+		huc3.advance(elapsed / 60)
+	}
+}
+
+func (huc3 *HuC3) SaveState(e *savestate.Encoder) {
+	e.Write(huc3.eram)
+	e.Write(huc3.romBank)
+	e.Write(huc3.ramBank)
+	e.Write(huc3.mode)
+	e.Write(huc3.isIRLEDOn)
+	e.Write([4]uint16{huc3.minutes, huc3.days, huc3.alarmMinutes, huc3.alarmDays})
+	e.Write(huc3.isAlarmOn)
+	e.Write([3]byte{huc3.accessIndex, huc3.accessFlags, huc3.response})
+	e.WriteInt(huc3.clockCycles)
+}
+
+func (huc3 *HuC3) LoadState(d *savestate.Decoder) {
+	d.Read(huc3.eram)
+	d.Read(&huc3.romBank)
+	d.Read(&huc3.ramBank)
+	d.Read(&huc3.mode)
+	d.Check(huc3.romBank <= 0x7F && huc3.ramBank <= 0x03 && huc3.mode <= 0x0F, "HuC3 bank")
+	d.Read(&huc3.isIRLEDOn)
+	var clock [4]uint16
+	d.Read(&clock)
+	huc3.minutes, huc3.days, huc3.alarmMinutes, huc3.alarmDays = clock[0], clock[1], clock[2], clock[3]
+	d.Read(&huc3.isAlarmOn)
+	var regs [3]byte
+	d.Read(&regs)
+	huc3.accessIndex, huc3.accessFlags, huc3.response = regs[0], regs[1], regs[2]
+	d.ReadInt(&huc3.clockCycles)
+}
@@ -0,0 +1,72 @@
+package mbc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHuC3SaveSize(t *testing.T) {
+	const ramSize = 0x2000
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{name: "RAM only", size: ramSize},
+		{name: "short", size: ramSize / 2},
+		{name: "clock footer", size: ramSize + HuC3FooterSize},
+		{name: "unknown footer", size: ramSize + RTCFooterSize, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sav := make([]byte, tt.size)
+			sav[0] = 0x5A
+			huc3, err := NewHuC3(newBankedROM(4), sav, 1)
+			if tt.wantErr {
+				var serr *SaveSizeError
+				if !errors.As(err, &serr) {
+					t.Errorf("err = %v, want *SaveSizeError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			huc3.WriteROM(0x0000, huc3ModeRAM)
+			if got := huc3.ReadERAM(0xA000); got != 0x5A {
+				t.Errorf("RAM was not loaded: %02X", got)
+			}
+		})
+	}
+}
+
+// The command 1 reads the clock nibbles, and 0 out of them.
+func TestHuC3ReadCommand(t *testing.T) {
+	huc3, err := NewHuC3(newBankedROM(4), nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huc3.minutes = 0x123
+	huc3.days = 0x0456
+
+	read := func(index byte) byte {
+		huc3.WriteROM(0x0000, huc3ModeCommand)
+		huc3.WriteERAM(0xA000, 0x40|index&0x0F)
+		huc3.WriteERAM(0xA000, 0x50|index>>4)
+		huc3.WriteERAM(0xA000, 0x10)
+		huc3.WriteROM(0x0000, huc3ModeResponse)
+		return huc3.ReadERAM(0xA000)
+	}
+	tests := []struct {
+		index byte
+		want  byte
+	}{
+		{0, 0x3}, {1, 0x2}, {2, 0x1}, {3, 0x6}, {4, 0x5}, {5, 0x4}, {6, 0x0}, {7, 0}, {0x10, 0},
+	}
+	for _, tt := range tests {
+		read(2) // Leaves a non-zero response.
+		if got := read(tt.index); got != tt.want {
+			t.Errorf("index %02X = %X, want %X", tt.index, got, tt.want)
+		}
+	}
+}
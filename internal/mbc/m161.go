@@ -0,0 +1,57 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+// The M161 is the mapper of the unlicensed "Mani 4 in 1" cartridges (e.g. Tetris Set).
+// The first write to 4000~5FFF selects a 32KiB bank for 0000~7FFF,
+// and further writes are ignored until reset.
+type M161 struct {
+	dirtyFlag
+
+	rom      []byte // =.gb data
+	bank     int    // 32KiB bank
+	isLocked bool
+}
+
+func NewM161(rom []byte) *M161 {
+	return &M161{rom: rom}
+}
+
+// Read from ROM in the current 32KiB bank
+func (m161 *M161) ReadROM(addr uint16) byte {
+	if addr >= 0x8000 {
+		return 0xFF
+	}
+	offset := m161.bank*0x8000 + int(addr)
+	return m161.rom[offset%len(m161.rom)]
+}
+
+// No RAM
+func (m161 *M161) ReadERAM(addr uint16) byte {
+	return 0xFF
+}
+
+func (m161 *M161) WriteROM(addr uint16, val byte) {
+	if addr >= 0x4000 && addr < 0x6000 && !m161.isLocked {
+		m161.bank = int(val & 0x07)
+		m161.isLocked = true
+	}
+}
+
+func (m161 *M161) WriteERAM(addr uint16, val byte) {
+}
+
+func (m161 *M161) GetSaveData() []byte {
+	return nil
+}
+
+func (m161 *M161) SaveState(e *savestate.Encoder) {
+	e.WriteInt(m161.bank)
+	e.Write(m161.isLocked)
+}
+
+func (m161 *M161) LoadState(d *savestate.Decoder) {
+	d.ReadInt(&m161.bank)
+	d.Check(m161.bank >= 0 && m161.bank <= 0x07, "M161 bank")
+	d.Read(&m161.isLocked)
+}
@@ -0,0 +1,31 @@
+package mbc
+
+import "testing"
+
+// The first write to 4000~5FFF selects a 32KiB bank, and the later ones are ignored.
+func TestM161ROMBanks(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []romWrite
+		wantBank0 byte // 16KiB bank at 0000~3FFF
+		wantBank  byte // 16KiB bank at 4000~7FFF
+	}{
+		{name: "reset", wantBank0: 0, wantBank: 1},
+		{name: "bank 3", writes: []romWrite{{0x4000, 0x03}}, wantBank0: 6, wantBank: 7},
+		{name: "upper bits are ignored", writes: []romWrite{{0x5FFF, 0xFA}}, wantBank0: 4, wantBank: 5},
+		{name: "locked after the first write", writes: []romWrite{{0x4000, 0x03}, {0x4000, 0x05}}, wantBank0: 6, wantBank: 7},
+		{name: "other addresses do not lock", writes: []romWrite{{0x2000, 0x01}, {0x6000, 0x02}, {0x4000, 0x03}}, wantBank0: 6, wantBank: 7},
+	}
+	for _, tt := range tests {
+		m161 := NewM161(newBankedROM(16))
+		for _, w := range tt.writes {
+			m161.WriteROM(w.addr, w.val)
+		}
+		if got := m161.ReadROM(0x0000); got != tt.wantBank0 {
+			t.Errorf("%s: bank at 0000 = %d, want %d", tt.name, got, tt.wantBank0)
+		}
+		if got := m161.ReadROM(0x4000); got != tt.wantBank {
+			t.Errorf("%s: bank at 4000 = %d, want %d", tt.name, got, tt.wantBank)
+		}
+	}
+}
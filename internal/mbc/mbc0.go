@@ -2,7 +2,11 @@
 
 package mbc
 
+import "gomeboy/internal/savestate"
+
 type MBC0 struct {
+	dirtyFlag
+
 	rom  []byte // =.gb data
 	eram []byte // =External RAM, SRAM
 }
@@ -33,7 +37,10 @@ func (mbc0 *MBC0) ReadROM(addr uint16) byte {
 func (mbc0 *MBC0) ReadERAM(addr uint16) byte {
 	switch {
 	case addr >= 0xA000 && addr < 0xC000:
-		return mbc0.eram[uint32(addr)-0xA000]
+		if len(mbc0.eram) == 0 {
+			return 0xFF
+		}
+		return mbc0.eram[int(addr-0xA000)%len(mbc0.eram)]
 	default:
 		return 0xFF
 	}
@@ -44,9 +51,21 @@ func (mbc0 *MBC0) WriteROM(addr uint16, val byte) {
 
 // Write to eram area
 func (mbc0 *MBC0) WriteERAM(addr uint16, val byte) {
-	mbc0.eram[uint32(addr)-0xA000] = val
+	if len(mbc0.eram) == 0 {
+		return
+	}
+	mbc0.eram[int(addr-0xA000)%len(mbc0.eram)] = val
+	mbc0.isDirty = true
 }
 
 func (mbc0 *MBC0) GetSaveData() []byte {
 	return mbc0.eram
 }
+
+func (mbc0 *MBC0) SaveState(e *savestate.Encoder) {
+	e.Write(mbc0.eram)
+}
+
+func (mbc0 *MBC0) LoadState(d *savestate.Decoder) {
+	d.Read(mbc0.eram)
+}
@@ -0,0 +1,18 @@
+package mbc
+
+import "testing"
+
+// Without cartridge RAM, ERAM reads are open bus and writes are ignored.
+func TestMBC0NoRAM(t *testing.T) {
+	mbc0 := NewMBC0(newBankedROM(2), nil, 0)
+	mbc0.WriteERAM(0xA000, 0x12)
+	if got := mbc0.ReadERAM(0xA000); got != 0xFF {
+		t.Errorf("ReadERAM(A000) = %02X, want FF", got)
+	}
+	if mbc0.IsDirty() {
+		t.Error("a write without cartridge RAM set the dirty flag")
+	}
+	if got := mbc0.ReadROM(0x4000); got != 1 {
+		t.Errorf("ReadROM(4000) = %02X, want bank 01", got)
+	}
+}
@@ -1,18 +1,29 @@
 package mbc
 
+import (
+	"bytes"
+	"gomeboy/internal/savestate"
+)
+
 type MBC1 struct {
+	dirtyFlag
+
 	rom         []byte // =.gb data
 	eram        []byte // =External RAM, SRAM
 	bankingMode byte
 	bankHigh    byte
 	romBankLow  byte
 	isRAMEnable bool
+
+	// MBC1M (multicart) wires the upper bank bits to ROM bank bits 4-5 instead of 5-6.
+	isMulticart bool
 }
 
 func NewMBC1(rom, sav []byte, TotalRAMBanks int) *MBC1 {
 	mbc1 := &MBC1{
-		rom:        rom,
-		romBankLow: 1,
+		rom:         rom,
+		romBankLow:  1,
+		isMulticart: isMBC1Multicart(rom),
 	}
 
 	mbc1.eram = make([]byte, TotalRAMBanks*0x2000)
@@ -22,19 +33,42 @@ func NewMBC1(rom, sav []byte, TotalRAMBanks int) *MBC1 {
 	return mbc1
 }
 
+// The isMBC1Multicart detects MBC1M by the heuristic used by other emulators:
+// a 1MiB ROM that has the Nintendo logo (= a game's header) at every 256KiB boundary.
+// (An ordinary 1MiB game may have the logo at one of them by chance.)
+func isMBC1Multicart(rom []byte) bool {
+	if len(rom) != 0x100000 {
+		return false
+	}
+	for base := 0; base < len(rom); base += 0x40000 {
+		if !bytes.Equal(rom[base+0x0104:base+0x0134], NintendoLogo) {
+			return false
+		}
+	}
+	return true
+}
+
 // Read from ROM in the current bank
 func (mbc1 *MBC1) ReadROM(addr uint16) byte {
+	// MBC1M: ROM bank = bankHigh(2bit) << 4 | romBankLow(lower 4bit)
+	shift := 5
+	bankLow := mbc1.romBankLow
+	if mbc1.isMulticart {
+		shift = 4
+		bankLow &= 0x0F
+	}
+
 	switch {
-	case addr < 0x4000: // ROM Bank $20/$40/$60
+	case addr < 0x4000: // ROM Bank $20/$40/$60 ($10/$20/$30 on MBC1M)
 		bank := byte(0)
 		if mbc1.bankingMode == 1 {
-			bank = mbc1.bankHigh << 5
+			bank = mbc1.bankHigh << shift
 		}
-		return mbc1.rom[0x4000*uint32(bank)+uint32(addr)]
+		return mbc1.rom[(0x4000*uint32(bank)+uint32(addr))%uint32(len(mbc1.rom))]
 
 	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 01-7F
-		bank := (mbc1.bankHigh << 5) | mbc1.romBankLow
-		return mbc1.rom[0x4000*uint32(bank)+uint32(addr-0x4000)]
+		bank := (mbc1.bankHigh << shift) | bankLow
+		return mbc1.rom[(0x4000*uint32(bank)+uint32(addr-0x4000))%uint32(len(mbc1.rom))]
 	default:
 		return 0xFF
 	}
@@ -44,14 +78,10 @@ func (mbc1 *MBC1) ReadROM(addr uint16) byte {
 func (mbc1 *MBC1) ReadERAM(addr uint16) byte {
 	switch {
 	case addr >= 0xA000 && addr < 0xC000:
-		if !mbc1.isRAMEnable {
+		if !mbc1.isRAMEnable || len(mbc1.eram) == 0 {
 			return 0xFF
 		}
-		bank := byte(0)
-		if mbc1.bankingMode == 1 {
-			bank = mbc1.bankHigh
-		}
-		return mbc1.eram[uint16(bank)*0x2000+addr-0xA000]
+		return mbc1.eram[mbc1.getRAMOffset(addr)]
 	default:
 		return 0xFF
 	}
@@ -85,17 +115,41 @@ func (mbc1 *MBC1) WriteROM(addr uint16, val byte) {
 func (mbc1 *MBC1) WriteERAM(addr uint16, val byte) {
 	switch {
 	case addr >= 0xA000 && addr < 0xC000:
-		if !mbc1.isRAMEnable {
+		if !mbc1.isRAMEnable || len(mbc1.eram) == 0 {
 			return
 		}
-		bank := byte(0)
-		if mbc1.bankingMode == 1 {
-			bank = mbc1.bankHigh
-		}
-		mbc1.eram[uint16(bank)*0x2000+addr-0xA000] = val
+		mbc1.eram[mbc1.getRAMOffset(addr)] = val
+		mbc1.isDirty = true
 	}
 }
 
+// The bank wraps around the real RAM size. (e.g. 2KiB or 8KiB RAM ignores the bank register)
+func (mbc1 *MBC1) getRAMOffset(addr uint16) int {
+	bank := 0
+	if mbc1.bankingMode == 1 {
+		bank = int(mbc1.bankHigh)
+	}
+	return (bank*0x2000 + int(addr-0xA000)) % len(mbc1.eram)
+}
+
 func (mbc1 *MBC1) GetSaveData() []byte {
 	return mbc1.eram
 }
+
+func (mbc1 *MBC1) SaveState(e *savestate.Encoder) {
+	e.Write(mbc1.eram)
+	e.Write(mbc1.bankingMode)
+	e.Write(mbc1.bankHigh)
+	e.Write(mbc1.romBankLow)
+	e.Write(mbc1.isRAMEnable)
+}
+
+func (mbc1 *MBC1) LoadState(d *savestate.Decoder) {
+	d.Read(mbc1.eram)
+	d.Read(&mbc1.bankingMode)
+	d.Read(&mbc1.bankHigh)
+	d.Read(&mbc1.romBankLow)
+	d.Read(&mbc1.isRAMEnable)
+	d.Check(mbc1.bankingMode <= 1 && mbc1.bankHigh <= 0x03, "MBC1 bank")
+	d.Check(mbc1.romBankLow >= 1 && mbc1.romBankLow <= 0x1F, "MBC1 ROM bank")
+}
@@ -0,0 +1,63 @@
+package mbc
+
+import "testing"
+
+func TestIsMBC1Multicart(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		logos []int // Addresses of the sub-ROMs with the logo
+		want  bool
+	}{
+		{name: "all sub-ROMs", size: 0x100000, logos: []int{0, 0x40000, 0x80000, 0xC0000}, want: true},
+		{name: "one boundary", size: 0x100000, logos: []int{0, 0x40000}, want: false},
+		{name: "all but the last", size: 0x100000, logos: []int{0, 0x40000, 0x80000}, want: false},
+		{name: "first only", size: 0x100000, logos: []int{0}, want: false},
+		{name: "not 1MiB", size: 0x80000, logos: []int{0, 0x40000}, want: false},
+	}
+	for _, tt := range tests {
+		rom := make([]byte, tt.size)
+		for _, base := range tt.logos {
+			copy(rom[base+0x0104:], NintendoLogo)
+		}
+		if got := isMBC1Multicart(rom); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// On a ROM smaller than the bank number, the bank wraps to the real ROM size.
+func TestMBC1ROMBankWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []romWrite
+		addr   uint16
+		want   byte
+	}{
+		{name: "bank 5 of 4", writes: []romWrite{{0x2000, 0x05}}, addr: 0x4000, want: 1},
+		{name: "bank 1F of 4", writes: []romWrite{{0x2000, 0x1F}}, addr: 0x4000, want: 3},
+		{name: "bank 21 of 4", writes: []romWrite{{0x2000, 0x01}, {0x4000, 0x01}}, addr: 0x4000, want: 1},
+		{name: "mode 1 bank 20 at 0000", writes: []romWrite{{0x4000, 0x01}, {0x6000, 0x01}}, addr: 0x0000, want: 0},
+	}
+	for _, tt := range tests {
+		mbc1 := NewMBC1(newBankedROM(4), nil, 0)
+		for _, w := range tt.writes {
+			mbc1.WriteROM(w.addr, w.val)
+		}
+		if got := mbc1.ReadROM(tt.addr); got != tt.want {
+			t.Errorf("%s: ReadROM(%04X) = bank %02X, want %02X", tt.name, tt.addr, got, tt.want)
+		}
+	}
+}
+
+// With 8KiB of RAM, the RAM bank in mode 1 wraps to bank 0.
+func TestMBC1RAMBankWrap(t *testing.T) {
+	mbc1 := NewMBC1(newBankedROM(4), nil, 1)
+	mbc1.WriteROM(0x0000, 0x0A)
+	mbc1.WriteERAM(0xA000, 0x12)
+	mbc1.WriteROM(0x6000, 0x01)
+	mbc1.WriteROM(0x4000, 0x03)
+	if got := mbc1.ReadERAM(0xA000); got != 0x12 {
+		t.Errorf("ReadERAM(A000) in RAM bank 3 = %02X, want 12 (bank 0)", got)
+	}
+}
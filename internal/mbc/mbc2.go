@@ -0,0 +1,98 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+type MBC2 struct {
+	dirtyFlag
+
+	rom         []byte      // =.gb data
+	ram         [0x200]byte // Built-in 512 x 4bit RAM (only the lower nibble is used)
+	romBank     byte
+	isRAMEnable bool
+}
+
+// MBC2 has the RAM inside, so the RAM size in the header is not used.
+func NewMBC2(rom, sav []byte) *MBC2 {
+	mbc2 := &MBC2{
+		rom:     rom,
+		romBank: 1,
+	}
+	if len(sav) <= len(mbc2.ram) {
+		copy(mbc2.ram[:], sav)
+	}
+	return mbc2
+}
+
+// Read from ROM in the current bank
+func (mbc2 *MBC2) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return mbc2.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 01 ~ 0F
+		offset := 0x4000*uint32(mbc2.romBank) + uint32(addr-0x4000)
+		return mbc2.rom[offset%uint32(len(mbc2.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+// Read from the built-in RAM.
+// A000~A1FF is echoed over A000~BFFF, and the upper nibble reads as 1s.
+func (mbc2 *MBC2) ReadERAM(addr uint16) byte {
+	switch {
+	case addr >= 0xA000 && addr < 0xC000:
+		if !mbc2.isRAMEnable {
+			return 0xFF
+		}
+		return 0xF0 | mbc2.ram[(addr-0xA000)&0x1FF]&0x0F
+	default:
+		return 0xFF
+	}
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+// Bit 8 of the address selects the register.
+func (mbc2 *MBC2) WriteROM(addr uint16, val byte) {
+	if addr >= 0x4000 {
+		return
+	}
+	if addr&(1<<8) == 0 { // RAM Enable
+		mbc2.isRAMEnable = val&0x0F == 0x0A
+	} else { // ROM Bank Number
+		mbc2.romBank = val & 0x0F
+		if mbc2.romBank == 0 {
+			mbc2.romBank = 1
+		}
+	}
+}
+
+// Write to the built-in RAM (only the lower nibble is stored)
+func (mbc2 *MBC2) WriteERAM(addr uint16, val byte) {
+	switch {
+	case addr >= 0xA000 && addr < 0xC000:
+		if !mbc2.isRAMEnable {
+			return
+		}
+		mbc2.ram[(addr-0xA000)&0x1FF] = val & 0x0F
+		mbc2.isDirty = true
+	}
+}
+
+func (mbc2 *MBC2) GetSaveData() []byte {
+	return mbc2.ram[:]
+}
+
+func (mbc2 *MBC2) SaveState(e *savestate.Encoder) {
+	e.Write(&mbc2.ram)
+	e.Write(mbc2.romBank)
+	e.Write(mbc2.isRAMEnable)
+}
+
+func (mbc2 *MBC2) LoadState(d *savestate.Decoder) {
+	d.Read(&mbc2.ram)
+	d.Read(&mbc2.romBank)
+	d.Read(&mbc2.isRAMEnable)
+	d.Check(mbc2.romBank >= 1 && mbc2.romBank <= 0x0F, "MBC2 ROM bank")
+}
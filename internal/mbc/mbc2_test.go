@@ -0,0 +1,82 @@
+package mbc
+
+import "testing"
+
+// Bit 8 of the address selects RAM enable (0) or the ROM bank (1) anywhere in 0000~3FFF.
+func TestMBC2Registers(t *testing.T) {
+	tests := []struct {
+		name       string
+		addr       uint16
+		val        byte
+		wantBank   byte
+		wantEnable bool
+	}{
+		{name: "ROM bank", addr: 0x2100, val: 0x05, wantBank: 5},
+		{name: "ROM bank at 0100", addr: 0x0100, val: 0x03, wantBank: 3},
+		{name: "ROM bank upper bits", addr: 0x3FFF, val: 0xF7, wantBank: 7},
+		{name: "bank 0 is bank 1", addr: 0x2100, val: 0x00, wantBank: 1},
+		{name: "bank $10 is bank 1", addr: 0x2100, val: 0x10, wantBank: 1},
+		{name: "RAM enable", addr: 0x0000, val: 0x0A, wantBank: 1, wantEnable: true},
+		{name: "RAM enable at 2000", addr: 0x2000, val: 0x0A, wantBank: 1, wantEnable: true},
+		{name: "RAM enable upper bits", addr: 0x1EFF, val: 0xFA, wantBank: 1, wantEnable: true},
+		{name: "RAM enable other value", addr: 0x0000, val: 0x0B, wantBank: 1},
+		{name: "above 3FFF", addr: 0x4100, val: 0x05, wantBank: 1},
+	}
+	for _, tt := range tests {
+		mbc2 := NewMBC2(newBankedROM(16), nil)
+		mbc2.WriteROM(tt.addr, tt.val)
+		if got := mbc2.ReadROM(0x4000); got != tt.wantBank {
+			t.Errorf("%s: bank = %d, want %d", tt.name, got, tt.wantBank)
+		}
+		if got := mbc2.ReadROM(0x0000); got != 0 {
+			t.Errorf("%s: 0000 reads bank %d, want 0", tt.name, got)
+		}
+		if mbc2.isRAMEnable != tt.wantEnable {
+			t.Errorf("%s: RAM enable = %v, want %v", tt.name, mbc2.isRAMEnable, tt.wantEnable)
+		}
+	}
+}
+
+// The 512 x 4bit RAM is echoed over A000~BFFF, and the upper nibble reads as 1s.
+func TestMBC2RAM(t *testing.T) {
+	mbc2 := NewMBC2(newBankedROM(16), nil)
+	mbc2.WriteERAM(0xA000, 0x05)
+	if got := mbc2.ReadERAM(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM reads %02X, want FF", got)
+	}
+	if mbc2.IsDirty() {
+		t.Error("a write to the disabled RAM made the save data dirty")
+	}
+
+	mbc2.WriteROM(0x0000, 0x0A)
+	mbc2.WriteERAM(0xA000, 0x35)
+	mbc2.WriteERAM(0xA1FF, 0xAC)
+	tests := []struct {
+		addr uint16
+		want byte
+	}{
+		{0xA000, 0xF5},
+		{0xA200, 0xF5},
+		{0xBE00, 0xF5},
+		{0xA1FF, 0xFC},
+		{0xBFFF, 0xFC},
+		{0xA001, 0xF0},
+	}
+	for _, tt := range tests {
+		if got := mbc2.ReadERAM(tt.addr); got != tt.want {
+			t.Errorf("%04X reads %02X, want %02X", tt.addr, got, tt.want)
+		}
+	}
+
+	// A write to an echo goes to the same nibble.
+	mbc2.WriteERAM(0xB000, 0x09)
+	if got := mbc2.ReadERAM(0xA000); got != 0xF9 {
+		t.Errorf("A000 reads %02X after writing B000, want F9", got)
+	}
+	if !mbc2.IsDirty() {
+		t.Error("the save data is not dirty after a write")
+	}
+	if data := mbc2.GetSaveData(); len(data) != 0x200 || data[0] != 0x09 || data[0x1FF] != 0x0C {
+		t.Errorf("save data: size %d, [0] = %02X, [1FF] = %02X", len(data), data[0], data[0x1FF])
+	}
+}
@@ -0,0 +1,335 @@
+package mbc
+
+import (
+	"encoding/binary"
+	"gomeboy/internal/savestate"
+	"time"
+)
+
+const CyclesPerRTCSecond = 4194304
+
+// The size of the RTC footer appended to the .sav file (BGB/VBA compatible).
+// Older files have a 32-bit timestamp, so the footer is 44 Bytes.
+const (
+	RTCFooterSize      = 48
+	RTCFooterSizeShort = 44
+)
+
+type MBC3 struct {
+	dirtyFlag
+
+	rom           []byte // =.gb data
+	eram          []byte // =External RAM, SRAM
+	romBank       byte
+	romBankMask   byte // 0x7F (0xFF on MBC30, with more than 2MiB ROM)
+	ramBank       byte // 00~07: RAM bank, 08~0C: RTC register
+	isRAMEnable   bool // RAM and RTC
+	totalRAMBanks int
+
+	// Real Time Clock (MBC3+TIMER only)
+	hasRTC    bool
+	rtc       rtcRegisters // Counting registers
+	latched   rtcRegisters // Registers visible to the CPU
+	prevLatch byte
+	rtcCycles int // CPU cycles within the current second
+}
+
+type rtcRegisters struct {
+	s  byte // Seconds 0~59
+	m  byte // Minutes 0~59
+	h  byte // Hours 0~23
+	dl byte // Lower 8 bits of Day Counter
+	dh byte // bit0: Upper 1 bit of Day Counter, bit6: Halt, bit7: Day Counter Carry
+}
+
+// The NewMBC3 returns a *SaveSizeError if sav has an unknown footer.
+// (An RTC footer for a cartridge without the RTC is ignored.)
+func NewMBC3(rom, sav []byte, TotalRAMBanks int, hasRTC bool) (*MBC3, error) {
+	mbc3 := &MBC3{
+		rom:           rom,
+		romBank:       1,
+		romBankMask:   0x7F,
+		totalRAMBanks: TotalRAMBanks,
+		hasRTC:        hasRTC,
+		prevLatch:     0xFF,
+	}
+	if len(rom) > 0x200000 {
+		mbc3.romBankMask = 0xFF
+	}
+
+	mbc3.eram = make([]byte, TotalRAMBanks*0x2000)
+	footerSize := len(sav) - len(mbc3.eram)
+	switch {
+	case footerSize <= 0:
+		copy(mbc3.eram, sav)
+	case footerSize == RTCFooterSize || footerSize == RTCFooterSizeShort:
+		copy(mbc3.eram, sav)
+		if hasRTC {
+			mbc3.loadRTCFooter(sav[len(mbc3.eram):])
+		}
+	default:
+		return nil, &SaveSizeError{Size: len(sav), RAMSize: len(mbc3.eram)}
+	}
+	return mbc3, nil
+}
+
+// Read from ROM in the current bank
+func (mbc3 *MBC3) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return mbc3.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 01 ~ 7F (~FF on MBC30)
+		offset := 0x4000*uint32(mbc3.romBank) + uint32(addr-0x4000)
+		return mbc3.rom[offset%uint32(len(mbc3.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+// Read from eram in the current bank, or the latched RTC register
+func (mbc3 *MBC3) ReadERAM(addr uint16) byte {
+	if !mbc3.isRAMEnable {
+		return 0xFF
+	}
+	switch {
+	case mbc3.ramBank < 0x08:
+		if mbc3.totalRAMBanks == 0 {
+			return 0xFF
+		}
+		bank := int(mbc3.ramBank) % mbc3.totalRAMBanks
+		return mbc3.eram[bank*0x2000+int(addr)-0xA000]
+	case mbc3.hasRTC:
+		return mbc3.latched.read(mbc3.ramBank)
+	default:
+		return 0xFF
+	}
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+func (mbc3 *MBC3) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // RAM and Timer Enable
+		mbc3.isRAMEnable = val&0x0F == 0x0A
+
+	// ROM Bank Number
+	case addr >= 0x2000 && addr < 0x4000:
+		mbc3.romBank = val & mbc3.romBankMask
+		if mbc3.romBank == 0 {
+			mbc3.romBank = 1
+		}
+
+	// RAM Bank Number or RTC Register Select
+	case addr >= 0x4000 && addr < 0x6000:
+		mbc3.ramBank = val & 0x0F
+
+	// Latch Clock Data (Writing 00 then 01 copies the RTC to the latched registers)
+	case addr >= 0x6000 && addr < 0x8000:
+		if mbc3.prevLatch == 0x00 && val == 0x01 {
+			mbc3.latched = mbc3.rtc
+		}
+		mbc3.prevLatch = val
+	}
+}
+
+// Write to eram area, or the RTC register
+func (mbc3 *MBC3) WriteERAM(addr uint16, val byte) {
+	if !mbc3.isRAMEnable {
+		return
+	}
+	switch {
+	case mbc3.ramBank < 0x08:
+		if mbc3.totalRAMBanks == 0 {
+			return
+		}
+		bank := int(mbc3.ramBank) % mbc3.totalRAMBanks
+		mbc3.eram[bank*0x2000+int(addr)-0xA000] = val
+		mbc3.isDirty = true
+	case mbc3.hasRTC:
+		if mbc3.ramBank == 0x08 {
+			mbc3.rtcCycles = 0 // Writing the seconds resets the sub-second counter.
+		}
+		mbc3.rtc.write(mbc3.ramBank, val)
+		mbc3.latched.write(mbc3.ramBank, val)
+		mbc3.isDirty = true
+	}
+}
+
+// The Step advances the RTC by the emulated time.
+// (cycles are in the normal speed clock, as the RTC has its own crystal.)
+func (mbc3 *MBC3) Step(cycles int) {
+	if !mbc3.hasRTC {
+		return
+	}
+	mbc3.rtcCycles += cycles
+	for mbc3.rtcCycles >= CyclesPerRTCSecond {
+		mbc3.rtcCycles -= CyclesPerRTCSecond
+		mbc3.rtc.tick()
+	}
+}
+
+// The save data is the eram followed by the RTC footer.
+func (mbc3 *MBC3) GetSaveData() []byte {
+	if !mbc3.hasRTC {
+		return mbc3.eram
+	}
+	data := make([]byte, len(mbc3.eram), len(mbc3.eram)+RTCFooterSize)
+	copy(data, mbc3.eram)
+	return append(data, mbc3.makeRTCFooter(time.Now())...)
+}
+
+// Footer: 5 current registers (uint32 each), 5 latched registers, UNIX time (uint64 or uint32)
+func (mbc3 *MBC3) makeRTCFooter(now time.Time) []byte {
+	footer := make([]byte, RTCFooterSize)
+	for i, r := range []*rtcRegisters{&mbc3.rtc, &mbc3.latched} {
+		for j, v := range []byte{r.s, r.m, r.h, r.dl, r.dh} {
+			binary.LittleEndian.PutUint32(footer[(i*5+j)*4:], uint32(v))
+		}
+	}
+	binary.LittleEndian.PutUint64(footer[40:], uint64(now.Unix()))
+	return footer
+}
+
+// The loadRTCFooter restores the RTC registers,
+// and advances the clock by the real time elapsed since the file was saved.
+func (mbc3 *MBC3) loadRTCFooter(footer []byte) {
+	for i, r := range []*rtcRegisters{&mbc3.rtc, &mbc3.latched} {
+		for j := byte(0x08); j <= 0x0C; j++ {
+			v := binary.LittleEndian.Uint32(footer[(i*5+int(j-0x08))*4:])
+			r.write(j, byte(v))
+		}
+	}
+	var savedAt int64
+	if len(footer) == RTCFooterSize {
+		savedAt = int64(binary.LittleEndian.Uint64(footer[40:]))
+	} else {
+		savedAt = int64(binary.LittleEndian.Uint32(footer[40:]))
+	}
+	if elapsed := time.Now().Unix() - savedAt; elapsed > 0 {
+		mbc3.rtc.advance(elapsed)
+	}
+}
+
+func (r *rtcRegisters) read(reg byte) byte {
+	switch reg {
+	case 0x08:
+		return r.s
+	case 0x09:
+		return r.m
+	case 0x0A:
+		return r.h
+	case 0x0B:
+		return r.dl
+	case 0x0C:
+		return r.dh
+	default:
+		return 0xFF
+	}
+}
+
+func (r *rtcRegisters) write(reg, val byte) {
+	switch reg {
+	case 0x08:
+		r.s = val & 0x3F
+	case 0x09:
+		r.m = val & 0x3F
+	case 0x0A:
+		r.h = val & 0x1F
+	case 0x0B:
+		r.dl = val
+	case 0x0C:
+		r.dh = val & 0xC1
+	}
+}
+
+func (r *rtcRegisters) isHalted() bool {
+	return r.dh&(1<<6) != 0
+}
+
+func (r *rtcRegisters) getDays() int {
+	return int(r.dh&0x01)<<8 | int(r.dl)
+}
+
+func (r *rtcRegisters) setDays(days int) {
+	if days >= 512 {
+		days %= 512
+		r.dh |= 1 << 7 // Day Counter Carry
+	}
+	r.dl = byte(days)
+	r.dh = r.dh&^0x01 | byte(days>>8)&0x01
+}
+
+// The tick advances the clock by one second.
+// Out of range values (e.g. 60 seconds written by the game) wrap around at the register width
+// without carrying, like the hardware.
+func (r *rtcRegisters) tick() {
+	if r.isHalted() {
+		return
+	}
+	r.s = (r.s + 1) & 0x3F
+	if r.s != 60 {
+		return
+	}
+	r.s = 0
+	r.m = (r.m + 1) & 0x3F
+	if r.m != 60 {
+		return
+	}
+	r.m = 0
+	r.h = (r.h + 1) & 0x1F
+	if r.h != 24 {
+		return
+	}
+	r.h = 0
+	r.setDays(r.getDays() + 1)
+}
+
+// The advance advances the clock by many seconds at once.
+func (r *rtcRegisters) advance(seconds int64) {
+	if r.isHalted() {
+		return
+	}
+	// Out of range values are ticked one by one until they become valid.
+	for seconds > 0 && (r.s >= 60 || r.m >= 60 || r.h >= 24) {
+		r.tick()
+		seconds--
+	}
+	total := int64(r.getDays())*86400 + int64(r.h)*3600 + int64(r.m)*60 + int64(r.s) + seconds
+	r.s = byte(total % 60)
+	r.m = byte(total / 60 % 60)
+	r.h = byte(total / 3600 % 24)
+	days := total / 86400
+	if days >= 512 {
+		r.dh |= 1 << 7
+		days %= 512
+	}
+	r.setDays(int(days))
+}
+
+func (mbc3 *MBC3) SaveState(e *savestate.Encoder) {
+	e.Write(mbc3.eram)
+	e.Write(mbc3.romBank)
+	e.Write(mbc3.ramBank)
+	e.Write(mbc3.isRAMEnable)
+	e.Write([5]byte{mbc3.rtc.s, mbc3.rtc.m, mbc3.rtc.h, mbc3.rtc.dl, mbc3.rtc.dh})
+	e.Write([5]byte{mbc3.latched.s, mbc3.latched.m, mbc3.latched.h, mbc3.latched.dl, mbc3.latched.dh})
+	e.Write(mbc3.prevLatch)
+	e.WriteInt(mbc3.rtcCycles)
+}
+
+func (mbc3 *MBC3) LoadState(d *savestate.Decoder) {
+	d.Read(mbc3.eram)
+	d.Read(&mbc3.romBank)
+	d.Read(&mbc3.ramBank)
+	d.Read(&mbc3.isRAMEnable)
+	d.Check(mbc3.romBank >= 1 && mbc3.romBank&^mbc3.romBankMask == 0, "MBC3 ROM bank")
+	d.Check(mbc3.ramBank <= 0x0F, "MBC3 RAM bank")
+	var rtc, latched [5]byte
+	d.Read(&rtc)
+	d.Read(&latched)
+	mbc3.rtc = rtcRegisters{s: rtc[0], m: rtc[1], h: rtc[2], dl: rtc[3], dh: rtc[4]}
+	mbc3.latched = rtcRegisters{s: latched[0], m: latched[1], h: latched[2], dl: latched[3], dh: latched[4]}
+	d.Read(&mbc3.prevLatch)
+	d.ReadInt(&mbc3.rtcCycles)
+}
@@ -0,0 +1,76 @@
+package mbc
+
+import (
+	"errors"
+	"testing"
+)
+
+// The newBankedROM returns a ROM whose banks start with their bank number.
+func newBankedROM(banks int) []byte {
+	rom := make([]byte, banks*0x4000)
+	for i := 0; i < banks; i++ {
+		rom[i*0x4000] = byte(i)
+	}
+	return rom
+}
+
+func TestMBC3ROMBankMask(t *testing.T) {
+	tests := []struct {
+		banks int
+		val   byte
+		want  byte
+	}{
+		{banks: 128, val: 0x05, want: 0x05},
+		{banks: 128, val: 0x85, want: 0x05}, // 2MiB: bit 7 is ignored.
+		{banks: 128, val: 0x80, want: 0x01}, // Masked to 0, so bank 1
+		{banks: 256, val: 0x85, want: 0x85}, // MBC30
+	}
+	for _, tt := range tests {
+		mbc3, err := NewMBC3(newBankedROM(tt.banks), nil, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mbc3.WriteROM(0x2000, tt.val)
+		if got := mbc3.ReadROM(0x4000); got != tt.want {
+			t.Errorf("%d banks: bank after writing %02X = %02X, want %02X", tt.banks, tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestMBC3SaveSize(t *testing.T) {
+	const ramSize = 0x2000
+	tests := []struct {
+		name    string
+		size    int
+		hasRTC  bool
+		wantErr bool
+	}{
+		{name: "RAM only", size: ramSize},
+		{name: "short", size: ramSize / 2},
+		{name: "RTC footer", size: ramSize + RTCFooterSize, hasRTC: true},
+		{name: "short RTC footer", size: ramSize + RTCFooterSizeShort, hasRTC: true},
+		{name: "RTC footer without RTC", size: ramSize + RTCFooterSize},
+		{name: "unknown footer", size: ramSize + 16, hasRTC: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sav := make([]byte, tt.size)
+			sav[0] = 0x5A
+			mbc3, err := NewMBC3(newBankedROM(4), sav, 1, tt.hasRTC)
+			if tt.wantErr {
+				var serr *SaveSizeError
+				if !errors.As(err, &serr) || serr.Size != tt.size || serr.RAMSize != ramSize {
+					t.Errorf("err = %v, want *SaveSizeError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			mbc3.WriteROM(0x0000, 0x0A)
+			if got := mbc3.ReadERAM(0xA000); got != 0x5A {
+				t.Errorf("RAM was not loaded: %02X", got)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package mbc
 
+import "gomeboy/internal/savestate"
+
 type MBC5 struct {
+	dirtyFlag
+
 	rom           []byte // =.gb data
 	eram          []byte // =External RAM, SRAM
 	romBankLo     byte   // lower 8bit
@@ -8,11 +12,17 @@ type MBC5 struct {
 	ramBank       byte
 	isRAMEnable   bool
 	totalRAMBanks int
+
+	// Rumble cartridges (MBC5+RBL) drive the motor with bit 3 of the RAM bank register.
+	hasRumble     bool
+	isRumbling    bool
+	rumbleHandler func(isOn bool)
 }
 
-func NewMBC5(rom, sav []byte, TotalRAMBanks int) *MBC5 {
+func NewMBC5(rom, sav []byte, TotalRAMBanks int, hasRumble bool) *MBC5 {
 	mbc5 := &MBC5{
-		rom: rom,
+		rom:       rom,
+		hasRumble: hasRumble,
 	}
 	mbc5.totalRAMBanks = TotalRAMBanks
 	mbc5.eram = make([]byte, mbc5.totalRAMBanks*0x2000)
@@ -30,7 +40,7 @@ func (mbc5 *MBC5) ReadROM(addr uint16) byte {
 
 	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 00 ~ 1FF
 		bank := uint32(mbc5.romBankHi)<<8 | uint32(mbc5.romBankLo)
-		return mbc5.rom[0x4000*bank+uint32(addr)-0x4000]
+		return mbc5.rom[(0x4000*bank+uint32(addr)-0x4000)%uint32(len(mbc5.rom))]
 	default:
 		return 0xFF
 	}
@@ -40,11 +50,11 @@ func (mbc5 *MBC5) ReadROM(addr uint16) byte {
 func (mbc5 *MBC5) ReadERAM(addr uint16) byte {
 	switch {
 	case addr >= 0xA000 && addr < 0xC000:
-		if !mbc5.isRAMEnable {
+		if !mbc5.isRAMEnable || mbc5.totalRAMBanks == 0 {
 			return 0xFF
 		}
-		bank := mbc5.ramBank
-		return mbc5.eram[uint32(bank)*0x2000+uint32(addr)-0xA000]
+		bank := int(mbc5.ramBank) % mbc5.totalRAMBanks
+		return mbc5.eram[bank*0x2000+int(addr)-0xA000]
 	default:
 		return 0xFF
 	}
@@ -65,24 +75,75 @@ func (mbc5 *MBC5) WriteROM(addr uint16, val byte) {
 	case addr >= 0x3000 && addr < 0x4000:
 		mbc5.romBankHi = val & 0x01
 
-	// Banking Mode Select
+	// RAM Bank Number (and the rumble motor)
 	case addr >= 0x4000 && addr < 0x6000:
-		mbc5.ramBank = val & 0x0F
+		if mbc5.hasRumble {
+			mbc5.ramBank = val & 0x07
+			mbc5.setRumble(val&(1<<3) != 0)
+		} else {
+			mbc5.ramBank = val & 0x0F
+		}
 	}
 }
 
+func (mbc5 *MBC5) setRumble(isOn bool) {
+	if mbc5.isRumbling == isOn {
+		return
+	}
+	mbc5.isRumbling = isOn
+	if mbc5.rumbleHandler != nil {
+		mbc5.rumbleHandler(isOn)
+	}
+}
+
+func (mbc5 *MBC5) HasRumble() bool {
+	return mbc5.hasRumble
+}
+
+// The handler is called every time the motor is turned on or off.
+func (mbc5 *MBC5) SetRumbleHandler(fn func(isOn bool)) {
+	mbc5.rumbleHandler = fn
+}
+
+func (mbc5 *MBC5) IsRumbling() bool {
+	return mbc5.isRumbling
+}
+
 // Write to eram area
 func (mbc5 *MBC5) WriteERAM(addr uint16, val byte) {
 	switch {
 	case addr >= 0xA000 && addr < 0xC000:
-		if !mbc5.isRAMEnable {
+		if !mbc5.isRAMEnable || mbc5.totalRAMBanks == 0 {
 			return
 		}
-		bank := mbc5.ramBank % byte(mbc5.totalRAMBanks)
-		mbc5.eram[uint32(bank)*0x2000+uint32(addr)-0xA000] = val
+		bank := int(mbc5.ramBank) % mbc5.totalRAMBanks
+		mbc5.eram[bank*0x2000+int(addr)-0xA000] = val
+		mbc5.isDirty = true
 	}
 }
 
 func (mbc5 *MBC5) GetSaveData() []byte {
 	return mbc5.eram
 }
+
+func (mbc5 *MBC5) SaveState(e *savestate.Encoder) {
+	e.Write(mbc5.eram)
+	e.Write(mbc5.romBankLo)
+	e.Write(mbc5.romBankHi)
+	e.Write(mbc5.ramBank)
+	e.Write(mbc5.isRAMEnable)
+	e.Write(mbc5.isRumbling)
+}
+
+func (mbc5 *MBC5) LoadState(d *savestate.Decoder) {
+	d.Read(mbc5.eram)
+	d.Read(&mbc5.romBankLo)
+	d.Read(&mbc5.romBankHi)
+	d.Read(&mbc5.ramBank)
+	d.Read(&mbc5.isRAMEnable)
+	d.Check(mbc5.romBankHi <= 1 && mbc5.ramBank <= 0x0F, "MBC5 bank")
+	// The motor of the frontend follows the restored state.
+	var isRumbling bool
+	d.Read(&isRumbling)
+	mbc5.setRumble(isRumbling)
+}
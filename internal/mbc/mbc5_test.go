@@ -0,0 +1,87 @@
+package mbc
+
+import (
+	"bytes"
+	"gomeboy/internal/savestate"
+	"testing"
+)
+
+// Loading a state turns the motor of the frontend on or off as in the state.
+func TestMBC5LoadStateRumble(t *testing.T) {
+	mbc5 := NewMBC5(newBankedROM(4), nil, 0, true)
+	var calls []bool
+	mbc5.SetRumbleHandler(func(isOn bool) { calls = append(calls, isOn) })
+
+	save := func() []byte {
+		var buf bytes.Buffer
+		e := savestate.NewEncoder(&buf)
+		mbc5.SaveState(e)
+		if e.Err() != nil {
+			t.Fatal(e.Err())
+		}
+		return buf.Bytes()
+	}
+	load := func(state []byte) {
+		d := savestate.NewDecoder(bytes.NewReader(state))
+		mbc5.LoadState(d)
+		if d.Err() != nil {
+			t.Fatal(d.Err())
+		}
+	}
+
+	off := save()
+	mbc5.WriteROM(0x4000, 0x08)
+	on := save()
+
+	load(off)
+	load(off)
+	load(on)
+	want := []bool{true, false, true}
+	if len(calls) != len(want) {
+		t.Fatalf("handler calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("handler calls = %v, want %v", calls, want)
+		}
+	}
+	if !mbc5.IsRumbling() {
+		t.Error("IsRumbling = false after loading the state with the motor on")
+	}
+}
+
+// On a ROM smaller than the bank number, the bank wraps to the real ROM size.
+func TestMBC5ROMBankWrap(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []romWrite
+		want   byte
+	}{
+		{name: "bank 0", writes: []romWrite{{0x2000, 0x00}}, want: 0},
+		{name: "bank 6 of 4", writes: []romWrite{{0x2000, 0x06}}, want: 2},
+		{name: "bank FF of 4", writes: []romWrite{{0x2000, 0xFF}}, want: 3},
+		{name: "bank 100 of 4", writes: []romWrite{{0x2000, 0x00}, {0x3000, 0x01}}, want: 0},
+		{name: "bank 1FF of 4", writes: []romWrite{{0x2000, 0xFF}, {0x3000, 0x01}}, want: 3},
+	}
+	for _, tt := range tests {
+		mbc5 := NewMBC5(newBankedROM(4), nil, 0, false)
+		for _, w := range tt.writes {
+			mbc5.WriteROM(w.addr, w.val)
+		}
+		if got := mbc5.ReadROM(0x4000); got != tt.want {
+			t.Errorf("%s: ReadROM(4000) = bank %02X, want %02X", tt.name, got, tt.want)
+		}
+	}
+}
+
+// The RAM bank wraps to the number of RAM banks.
+func TestMBC5RAMBankWrap(t *testing.T) {
+	mbc5 := NewMBC5(newBankedROM(4), nil, 4, false)
+	mbc5.WriteROM(0x0000, 0x0A)
+	mbc5.WriteROM(0x4000, 0x01)
+	mbc5.WriteERAM(0xA000, 0x12)
+	mbc5.WriteROM(0x4000, 0x05)
+	if got := mbc5.ReadERAM(0xA000); got != 0x12 {
+		t.Errorf("ReadERAM(A000) in RAM bank 5 = %02X, want 12 (bank 1)", got)
+	}
+}
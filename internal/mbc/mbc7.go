@@ -0,0 +1,354 @@
+package mbc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"gomeboy/internal/savestate"
+)
+
+// Accelerometer values (Level = 0x81D0, about 0x70 per 1G)
+const (
+	AccelCenter = 0x81D0
+	AccelPerG   = 0x70
+)
+
+// The MBC7 has an accelerometer and a 93LC56 serial EEPROM (128 x 16bit) instead of SRAM.
+type MBC7 struct {
+	rom          []byte // =.gb data
+	romBank      byte
+	isRAMEnable1 bool // 0000~1FFF = 0x0A
+	isRAMEnable2 bool // 4000~5FFF = 0x40
+
+	// Accelerometer
+	tiltX, tiltY   float64 // Current tilt in G (from the host)
+	latchX, latchY uint16
+	isLatchErased  bool
+	eeprom         eeprom93LC56
+}
+
+// The NewMBC7 returns a *SaveSizeError if sav is larger than the EEPROM.
+func NewMBC7(rom, sav []byte) (*MBC7, error) {
+	mbc7 := &MBC7{
+		rom:     rom,
+		romBank: 1,
+		latchX:  0x8000,
+		latchY:  0x8000,
+	}
+	if err := mbc7.eeprom.init(sav); err != nil {
+		return nil, err
+	}
+	return mbc7, nil
+}
+
+// Read from ROM in the current bank
+func (mbc7 *MBC7) ReadROM(addr uint16) byte {
+	switch {
+	case addr < 0x4000:
+		return mbc7.rom[addr]
+
+	case addr >= 0x4000 && addr < 0x8000: // ROM Bank 00 ~ 7F
+		offset := 0x4000*uint32(mbc7.romBank) + uint32(addr-0x4000)
+		return mbc7.rom[offset%uint32(len(mbc7.rom))]
+	default:
+		return 0xFF
+	}
+}
+
+// A000~AFFF are registers selected by address bits 4-7.
+func (mbc7 *MBC7) ReadERAM(addr uint16) byte {
+	if !mbc7.isRAMEnable1 || !mbc7.isRAMEnable2 || addr >= 0xB000 {
+		return 0xFF
+	}
+	switch (addr >> 4) & 0x0F {
+	case 0x2: // Accelerometer X (low)
+		return byte(mbc7.latchX)
+	case 0x3: // Accelerometer X (high)
+		return byte(mbc7.latchX >> 8)
+	case 0x4: // Accelerometer Y (low)
+		return byte(mbc7.latchY)
+	case 0x5: // Accelerometer Y (high)
+		return byte(mbc7.latchY >> 8)
+	case 0x6:
+		return 0x00
+	case 0x8: // EEPROM
+		return mbc7.eeprom.read()
+	default:
+		return 0xFF
+	}
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+func (mbc7 *MBC7) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // RAM Enable 1
+		mbc7.isRAMEnable1 = val&0x0F == 0x0A
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		mbc7.romBank = val & 0x7F
+	case addr >= 0x4000 && addr < 0x6000: // RAM Enable 2
+		mbc7.isRAMEnable2 = val == 0x40
+	}
+}
+
+func (mbc7 *MBC7) WriteERAM(addr uint16, val byte) {
+	if !mbc7.isRAMEnable1 || !mbc7.isRAMEnable2 || addr >= 0xB000 {
+		return
+	}
+	switch (addr >> 4) & 0x0F {
+	case 0x0: // Erase the latched values
+		if val == 0x55 {
+			mbc7.latchX = 0x8000
+			mbc7.latchY = 0x8000
+			mbc7.isLatchErased = true
+		}
+	case 0x1: // Latch the accelerometer (only after erasing)
+		if val == 0xAA && mbc7.isLatchErased {
+			mbc7.latchX = toAccelValue(mbc7.tiltX)
+			mbc7.latchY = toAccelValue(mbc7.tiltY)
+			mbc7.isLatchErased = false
+		}
+	case 0x8: // EEPROM
+		mbc7.eeprom.write(val)
+	}
+}
+
+func toAccelValue(g float64) uint16 {
+	g = max(-4.0, min(4.0, g))
+	return uint16(AccelCenter + int(g*AccelPerG))
+}
+
+// The SetAcceleration sets the current tilt in G.
+// (x > 0: tilted to the right, y > 0: tilted toward the player)
+func (mbc7 *MBC7) SetAcceleration(x, y float64) {
+	mbc7.tiltX = x
+	mbc7.tiltY = y
+}
+
+// The save data is the EEPROM.
+func (mbc7 *MBC7) IsDirty() bool {
+	return mbc7.eeprom.IsDirty()
+}
+
+func (mbc7 *MBC7) ClearDirty() {
+	mbc7.eeprom.ClearDirty()
+}
+
+func (mbc7 *MBC7) GetSaveData() []byte {
+	return mbc7.eeprom.getData()
+}
+
+func (mbc7 *MBC7) SaveState(e *savestate.Encoder) {
+	e.Write(mbc7.romBank)
+	e.Write(mbc7.isRAMEnable1)
+	e.Write(mbc7.isRAMEnable2)
+	e.Write(mbc7.latchX)
+	e.Write(mbc7.latchY)
+	e.Write(mbc7.isLatchErased)
+	mbc7.eeprom.saveState(e)
+}
+
+func (mbc7 *MBC7) LoadState(d *savestate.Decoder) {
+	d.Read(&mbc7.romBank)
+	d.Check(mbc7.romBank <= 0x7F, "MBC7 ROM bank")
+	d.Read(&mbc7.isRAMEnable1)
+	d.Read(&mbc7.isRAMEnable2)
+	d.Read(&mbc7.latchX)
+	d.Read(&mbc7.latchY)
+	d.Read(&mbc7.isLatchErased)
+	mbc7.eeprom.loadState(d)
+}
+
+// ===================================== 93LC56 EEPROM ===============================================
+
+// EEPROM states
+const (
+	eepromIdle     = iota // Waiting for the start bit
+	eepromCommand         // Receiving opcode(2bit) + address(8bit)
+	eepromRead            // Sending 16bit data
+	eepromWrite           // Receiving 16bit data for WRITE
+	eepromWriteAll        // Receiving 16bit data for WRAL
+)
+
+type eeprom93LC56 struct {
+	dirtyFlag
+
+	words [128]uint16
+
+	// Pins (A080: bit7=CS, bit6=CLK, bit1=DI, bit0=DO)
+	cs, clk, di, do bool
+
+	state          int
+	bits           int    // Number of bits received/sent in the current state
+	shift          uint16 // Shift register for the command/data
+	addr           byte
+	isWriteEnabled bool
+}
+
+// Without save data, the EEPROM is erased (all 1s).
+// A short save is loaded into the first words, and the rest is erased.
+func (ee *eeprom93LC56) init(sav []byte) error {
+	data := bytes.Repeat([]byte{0xFF}, len(ee.words)*2)
+	if len(sav) > len(data) {
+		return &SaveSizeError{Size: len(sav), RAMSize: len(data)}
+	}
+	copy(data, sav)
+	for i := range ee.words {
+		ee.words[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	ee.do = true
+	return nil
+}
+
+func (ee *eeprom93LC56) getData() []byte {
+	data := make([]byte, len(ee.words)*2)
+	for i, w := range ee.words {
+		binary.LittleEndian.PutUint16(data[i*2:], w)
+	}
+	return data
+}
+
+func (ee *eeprom93LC56) read() byte {
+	v := byte(0)
+	if ee.cs {
+		v |= 1 << 7
+	}
+	if ee.clk {
+		v |= 1 << 6
+	}
+	if ee.di {
+		v |= 1 << 1
+	}
+	if ee.do {
+		v |= 1 << 0
+	}
+	return v
+}
+
+// Bits are shifted on the rising edge of CLK while CS is high.
+func (ee *eeprom93LC56) write(val byte) {
+	cs := val&(1<<7) != 0
+	clk := val&(1<<6) != 0
+	ee.di = val&(1<<1) != 0
+
+	if !cs {
+		ee.state = eepromIdle
+	}
+	isRisingEdge := cs && !ee.clk && clk
+	ee.cs = cs
+	ee.clk = clk
+	if isRisingEdge {
+		ee.clock()
+	}
+}
+
+func (ee *eeprom93LC56) clock() {
+	di := uint16(0)
+	if ee.di {
+		di = 1
+	}
+	switch ee.state {
+	case eepromIdle:
+		if di == 1 { // Start bit
+			ee.state = eepromCommand
+			ee.bits = 0
+			ee.shift = 0
+		}
+	case eepromCommand:
+		ee.shift = ee.shift<<1 | di
+		ee.bits++
+		if ee.bits == 10 {
+			ee.execute()
+		}
+	case eepromRead:
+		ee.do = ee.shift&0x8000 != 0
+		ee.shift <<= 1
+		ee.bits++
+		if ee.bits == 16 { // Sequential read continues with the next word.
+			ee.addr = (ee.addr + 1) & 0x7F
+			ee.shift = ee.words[ee.addr]
+			ee.bits = 0
+		}
+	case eepromWrite, eepromWriteAll:
+		ee.shift = ee.shift<<1 | di
+		ee.bits++
+		if ee.bits == 16 {
+			if ee.isWriteEnabled {
+				ee.isDirty = true
+				if ee.state == eepromWrite {
+					ee.words[ee.addr] = ee.shift
+				} else {
+					for i := range ee.words {
+						ee.words[i] = ee.shift
+					}
+				}
+			}
+			ee.do = true // Ready (writing completes instantly)
+			ee.state = eepromIdle
+		}
+	}
+}
+
+// Commands: opcode(2bit) + address(8bit, the MSB is ignored)
+func (ee *eeprom93LC56) execute() {
+	opcode := ee.shift >> 8 & 0x03
+	ee.addr = byte(ee.shift) & 0x7F
+	ee.bits = 0
+	ee.state = eepromIdle
+	switch opcode {
+	case 0b10: // READ (a dummy 0 bit, then 16bit data)
+		ee.do = false
+		ee.shift = ee.words[ee.addr]
+		ee.state = eepromRead
+	case 0b01: // WRITE
+		ee.shift = 0
+		ee.state = eepromWrite
+	case 0b11: // ERASE
+		if ee.isWriteEnabled {
+			ee.words[ee.addr] = 0xFFFF
+			ee.isDirty = true
+		}
+		ee.do = true
+	case 0b00:
+		switch byte(ee.shift) >> 6 { // The upper 2 bits of the address select the command.
+		case 0b11: // EWEN
+			ee.isWriteEnabled = true
+		case 0b00: // EWDS
+			ee.isWriteEnabled = false
+		case 0b10: // ERAL
+			if ee.isWriteEnabled {
+				for i := range ee.words {
+					ee.words[i] = 0xFFFF
+				}
+				ee.isDirty = true
+			}
+			ee.do = true
+		case 0b01: // WRAL
+			ee.shift = 0
+			ee.state = eepromWriteAll
+		}
+	}
+}
+
+func (ee *eeprom93LC56) saveState(e *savestate.Encoder) {
+	e.Write(&ee.words)
+	e.Write([4]bool{ee.cs, ee.clk, ee.di, ee.do})
+	e.WriteInt(ee.state)
+	e.WriteInt(ee.bits)
+	e.Write(ee.shift)
+	e.Write(ee.addr)
+	e.Write(ee.isWriteEnabled)
+}
+
+func (ee *eeprom93LC56) loadState(d *savestate.Decoder) {
+	d.Read(&ee.words)
+	var pins [4]bool
+	d.Read(&pins)
+	ee.cs, ee.clk, ee.di, ee.do = pins[0], pins[1], pins[2], pins[3]
+	d.ReadInt(&ee.state)
+	d.ReadInt(&ee.bits)
+	d.Check(ee.state >= eepromIdle && ee.state <= eepromWriteAll && ee.bits >= 0 && ee.bits <= 16, "EEPROM state")
+	d.Read(&ee.shift)
+	d.Read(&ee.addr)
+	d.Check(ee.addr <= 0x7F, "EEPROM address")
+	d.Read(&ee.isWriteEnabled)
+}
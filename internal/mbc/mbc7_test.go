@@ -0,0 +1,254 @@
+package mbc
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// EEPROM commands (opcode + address)
+const (
+	eeREAD  = 0b10 << 8
+	eeWRITE = 0b01 << 8
+	eeERASE = 0b11 << 8
+	eeEWEN  = 0b00<<8 | 0xC0
+	eeEWDS  = 0b00<<8 | 0x00
+	eeERAL  = 0b00<<8 | 0x80
+	eeWRAL  = 0b00<<8 | 0x40
+)
+
+// The newTestMBC7 returns an MBC7 with the RAM enabled. The word i of the EEPROM is 0x1000+i.
+func newTestMBC7(t *testing.T) *MBC7 {
+	t.Helper()
+	sav := make([]byte, 256)
+	for i := 0; i < 128; i++ {
+		binary.LittleEndian.PutUint16(sav[i*2:], uint16(0x1000+i))
+	}
+	mbc7, err := NewMBC7(newBankedROM(4), sav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mbc7.WriteROM(0x0000, 0x0A)
+	mbc7.WriteROM(0x4000, 0x40)
+	return mbc7
+}
+
+// The clockEEPROM sends a bit on the rising edge of CLK and returns DO after it.
+func clockEEPROM(mbc7 *MBC7, di uint16) uint16 {
+	mbc7.WriteERAM(0xA080, 0x80|byte(di)<<1)
+	mbc7.WriteERAM(0xA080, 0xC0|byte(di)<<1)
+	return uint16(mbc7.ReadERAM(0xA080) & 0x01)
+}
+
+func sendEEPROM(mbc7 *MBC7, bits uint16, n int) {
+	for i := n - 1; i >= 0; i-- {
+		clockEEPROM(mbc7, bits>>i&1)
+	}
+}
+
+// The commandEEPROM starts a command with CS low to high, then the start bit.
+func commandEEPROM(mbc7 *MBC7, cmd uint16) {
+	mbc7.WriteERAM(0xA080, 0x00)
+	sendEEPROM(mbc7, 1<<10|cmd, 11)
+}
+
+func readEEPROMWord(mbc7 *MBC7) uint16 {
+	var w uint16
+	for range 16 {
+		w = w<<1 | clockEEPROM(mbc7, 0)
+	}
+	return w
+}
+
+// READ outputs a dummy 0 bit, then the words from the address until CS goes low.
+func TestMBC7EEPROMRead(t *testing.T) {
+	mbc7 := newTestMBC7(t)
+	commandEEPROM(mbc7, eeREAD|0x05)
+	if do := mbc7.ReadERAM(0xA080) & 0x01; do != 0 {
+		t.Errorf("dummy bit = %d, want 0", do)
+	}
+	for _, want := range []uint16{0x1005, 0x1006, 0x1007} {
+		if got := readEEPROMWord(mbc7); got != want {
+			t.Errorf("read %04X, want %04X", got, want)
+		}
+	}
+
+	// The address MSB is ignored, and the sequential read wraps around.
+	commandEEPROM(mbc7, eeREAD|0xFF)
+	for _, want := range []uint16{0x107F, 0x1000} {
+		if got := readEEPROMWord(mbc7); got != want {
+			t.Errorf("read %04X, want %04X", got, want)
+		}
+	}
+}
+
+// WRITE, ERASE, ERAL and WRAL change the EEPROM only after EWEN.
+func TestMBC7EEPROMWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(mbc7 *MBC7)
+		want func(i int) uint16 // Word i after the command
+	}{
+		{
+			name: "WRITE",
+			run: func(mbc7 *MBC7) {
+				commandEEPROM(mbc7, eeWRITE|0x03)
+				sendEEPROM(mbc7, 0xABCD, 16)
+			},
+			want: func(i int) uint16 {
+				if i == 3 {
+					return 0xABCD
+				}
+				return uint16(0x1000 + i)
+			},
+		},
+		{
+			name: "ERASE",
+			run:  func(mbc7 *MBC7) { commandEEPROM(mbc7, eeERASE|0x7F) },
+			want: func(i int) uint16 {
+				if i == 0x7F {
+					return 0xFFFF
+				}
+				return uint16(0x1000 + i)
+			},
+		},
+		{
+			name: "ERAL",
+			run:  func(mbc7 *MBC7) { commandEEPROM(mbc7, eeERAL) },
+			want: func(i int) uint16 { return 0xFFFF },
+		},
+		{
+			name: "WRAL",
+			run: func(mbc7 *MBC7) {
+				commandEEPROM(mbc7, eeWRAL)
+				sendEEPROM(mbc7, 0x1234, 16)
+			},
+			want: func(i int) uint16 { return 0x1234 },
+		},
+	}
+	for _, tt := range tests {
+		for _, isEnabled := range []bool{false, true} {
+			mbc7 := newTestMBC7(t)
+			if isEnabled {
+				commandEEPROM(mbc7, eeEWEN)
+			}
+			tt.run(mbc7)
+			for i, got := range mbc7.eeprom.words {
+				want := uint16(0x1000 + i)
+				if isEnabled {
+					want = tt.want(i)
+				}
+				if got != want {
+					t.Errorf("%s (EWEN %v): word %02X = %04X, want %04X", tt.name, isEnabled, i, got, want)
+					break
+				}
+			}
+			if mbc7.IsDirty() != isEnabled {
+				t.Errorf("%s (EWEN %v): dirty = %v", tt.name, isEnabled, mbc7.IsDirty())
+			}
+			if do := mbc7.ReadERAM(0xA080) & 0x01; do != 1 {
+				t.Errorf("%s (EWEN %v): DO = %d after the command, want 1 (ready)", tt.name, isEnabled, do)
+			}
+		}
+	}
+}
+
+func TestMBC7EEPROMDisableWrite(t *testing.T) {
+	mbc7 := newTestMBC7(t)
+	commandEEPROM(mbc7, eeEWEN)
+	commandEEPROM(mbc7, eeEWDS)
+	commandEEPROM(mbc7, eeERAL)
+	if mbc7.eeprom.words[0] != 0x1000 || mbc7.IsDirty() {
+		t.Error("ERAL erased the EEPROM after EWDS")
+	}
+}
+
+func TestMBC7SaveSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		want    uint16 // Last word
+		wantErr bool
+	}{
+		{name: "no save", size: 0, want: 0xFFFF},
+		{name: "full", size: 256, want: 0x7F7F},
+		{name: "short", size: 100, want: 0xFFFF},
+		{name: "too large", size: 257, wantErr: true},
+	}
+	for _, tt := range tests {
+		sav := make([]byte, tt.size)
+		for i := range sav {
+			sav[i] = byte(i / 2)
+		}
+		mbc7, err := NewMBC7(newBankedROM(4), sav)
+		if tt.wantErr {
+			var serr *SaveSizeError
+			if !errors.As(err, &serr) || serr.Size != tt.size || serr.RAMSize != 256 {
+				t.Errorf("%s: err = %v, want SaveSizeError", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := mbc7.eeprom.words[127]; got != tt.want {
+			t.Errorf("%s: last word = %04X, want %04X", tt.name, got, tt.want)
+		}
+		if tt.size > 0 && mbc7.eeprom.words[1] != 0x0101 {
+			t.Errorf("%s: word 1 = %04X, want 0101", tt.name, mbc7.eeprom.words[1])
+		}
+	}
+}
+
+// The accelerometer is latched by writing 55 to A000, then AA to A010.
+func TestMBC7Accelerometer(t *testing.T) {
+	readLatch := func(mbc7 *MBC7) (x, y uint16) {
+		x = uint16(mbc7.ReadERAM(0xA030))<<8 | uint16(mbc7.ReadERAM(0xA020))
+		y = uint16(mbc7.ReadERAM(0xA050))<<8 | uint16(mbc7.ReadERAM(0xA040))
+		return x, y
+	}
+	mbc7 := newTestMBC7(t)
+	mbc7.SetAcceleration(1, -0.5)
+
+	mbc7.WriteERAM(0xA010, 0xAA)
+	if x, y := readLatch(mbc7); x != 0x8000 || y != 0x8000 {
+		t.Errorf("latched without erasing: %04X, %04X", x, y)
+	}
+
+	mbc7.WriteERAM(0xA000, 0x55)
+	mbc7.WriteERAM(0xA010, 0xAA)
+	if x, y := readLatch(mbc7); x != AccelCenter+AccelPerG || y != AccelCenter-AccelPerG/2 {
+		t.Errorf("latched %04X, %04X, want %04X, %04X", x, y, AccelCenter+AccelPerG, AccelCenter-AccelPerG/2)
+	}
+
+	// The latch needs another erase.
+	mbc7.SetAcceleration(0, 0)
+	mbc7.WriteERAM(0xA010, 0xAA)
+	if x, _ := readLatch(mbc7); x != AccelCenter+AccelPerG {
+		t.Errorf("latched again without erasing: %04X", x)
+	}
+	mbc7.WriteERAM(0xA000, 0x55)
+	if x, y := readLatch(mbc7); x != 0x8000 || y != 0x8000 {
+		t.Errorf("erased latch: %04X, %04X, want 8000", x, y)
+	}
+}
+
+func TestToAccelValue(t *testing.T) {
+	tests := []struct {
+		g    float64
+		want uint16
+	}{
+		{0, AccelCenter},
+		{1, AccelCenter + AccelPerG},
+		{-2, AccelCenter - 2*AccelPerG},
+		{4, AccelCenter + 4*AccelPerG},
+		{10, AccelCenter + 4*AccelPerG},
+		{-4, AccelCenter - 4*AccelPerG},
+		{-10, AccelCenter - 4*AccelPerG},
+	}
+	for _, tt := range tests {
+		if got := toAccelValue(tt.g); got != tt.want {
+			t.Errorf("toAccelValue(%g) = %04X, want %04X", tt.g, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package mbc
+
+import (
+	"bytes"
+	"errors"
+	"gomeboy/internal/savestate"
+	"testing"
+)
+
+// A bank register out of the range of its register is rejected by LoadState.
+func TestLoadStateRejectsOutOfRange(t *testing.T) {
+	rom := newBankedROM(8)
+	tests := []struct {
+		name   string
+		newMBC func() MBC
+		modify func(m MBC)
+	}{
+		{"MBC1 ROM bank", func() MBC { return NewMBC1(rom, nil, 0) }, func(m MBC) { m.(*MBC1).romBankLow = 0 }},
+		{"MBC1 bank", func() MBC { return NewMBC1(rom, nil, 0) }, func(m MBC) { m.(*MBC1).bankHigh = 4 }},
+		{"MBC2 ROM bank", func() MBC { return NewMBC2(rom, nil) }, func(m MBC) { m.(*MBC2).romBank = 0x10 }},
+		{"MBC3 ROM bank", func() MBC { m, _ := NewMBC3(rom, nil, 0, false); return m }, func(m MBC) { m.(*MBC3).romBank = 0x80 }},
+		{"MBC3 RAM bank", func() MBC { m, _ := NewMBC3(rom, nil, 0, false); return m }, func(m MBC) { m.(*MBC3).ramBank = 0x10 }},
+		{"MBC5 bank", func() MBC { return NewMBC5(rom, nil, 0, false) }, func(m MBC) { m.(*MBC5).romBankHi = 2 }},
+		{"HuC1 bank", func() MBC { return NewHuC1(rom, nil, 0) }, func(m MBC) { m.(*HuC1).romBank = 0x40 }},
+		{"MBC7 ROM bank", func() MBC { m, _ := NewMBC7(rom, nil); return m }, func(m MBC) { m.(*MBC7).romBank = 0x80 }},
+		{"EEPROM address", func() MBC { m, _ := NewMBC7(rom, nil); return m }, func(m MBC) { m.(*MBC7).eeprom.addr = 0x80 }},
+		{"MMM01 RAM bank", func() MBC { return NewMMM01(rom, nil, 0) }, func(m MBC) { m.(*MMM01).ramBankMask = 4 }},
+		{"WisdomTree bank", func() MBC { return NewWisdomTree(rom) }, func(m MBC) { m.(*WisdomTree).bank = -1 }},
+		{"M161 bank", func() MBC { return NewM161(rom) }, func(m MBC) { m.(*M161).bank = 8 }},
+		{"Sachen ROM bank", func() MBC { return NewSachen(rom) }, func(m MBC) { m.(*Sachen).romBank = 0 }},
+	}
+	for _, tt := range tests {
+		m := tt.newMBC()
+		tt.modify(m)
+		var buf bytes.Buffer
+		e := savestate.NewEncoder(&buf)
+		m.SaveState(e)
+		if e.Err() != nil {
+			t.Fatal(e.Err())
+		}
+
+		d := savestate.NewDecoder(&buf)
+		tt.newMBC().LoadState(d)
+		var rerr *savestate.RangeError
+		if !errors.As(d.Err(), &rerr) || rerr.Name != tt.name {
+			t.Errorf("%s: err = %v, want the RangeError", tt.name, d.Err())
+		}
+	}
+}
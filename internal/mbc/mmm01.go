@@ -0,0 +1,169 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+// The MMM01 is the mapper of multi-game cartridges.
+// It starts in the "unmapped" mode, where the menu in the last 32KiB of the ROM is mapped.
+// When the menu writes bit 6 to 0000~1FFF, the outer bank bits and masks are locked,
+// and it works like an MBC1 within the selected game.
+type MMM01 struct {
+	dirtyFlag
+
+	rom           []byte // =.gb data
+	eram          []byte // =External RAM, SRAM
+	isRAMEnable   bool
+	totalRAMBanks int
+	isMapped      bool // Locked by bit 6 of 0000~1FFF
+
+	romBankLow  byte // 5bit (2000~3FFF bit 0-4)
+	romBankMid  byte // 2bit (2000~3FFF bit 5-6, unmapped mode only)
+	romBankHigh byte // 2bit (4000~5FFF bit 4-5, unmapped mode only)
+	romBankMask byte // 4bit (6000~7FFF bit 2-5, unmapped mode only), masks bit 1-4 of romBankLow
+	ramBankLow  byte // 2bit (4000~5FFF bit 0-1)
+	ramBankHigh byte // 2bit (4000~5FFF bit 2-3, unmapped mode only)
+	ramBankMask byte // 2bit (0000~1FFF bit 4-5, unmapped mode only)
+
+	isMBC1Mode        bool // 6000~7FFF bit 0
+	isMBC1ModeLocked  bool // 4000~5FFF bit 6 (unmapped mode only)
+	isMultiplexEnable bool // 6000~7FFF bit 6 (unmapped mode only), swaps romBankMid and ramBankLow
+}
+
+func NewMMM01(rom, sav []byte, TotalRAMBanks int) *MMM01 {
+	mmm01 := &MMM01{
+		rom:           rom,
+		totalRAMBanks: TotalRAMBanks,
+	}
+	mmm01.eram = make([]byte, TotalRAMBanks*0x2000)
+	if len(sav) <= len(mmm01.eram) {
+		copy(mmm01.eram, sav)
+	}
+	return mmm01
+}
+
+// The getROMBanks returns the banks mapped to 0000~3FFF and 4000~7FFF.
+func (mmm01 *MMM01) getROMBanks() (bank0, bank int) {
+	romBanks := len(mmm01.rom) / 0x4000
+	if !mmm01.isMapped { // The last 32KiB
+		return romBanks - 2, romBanks - 1
+	}
+	mid := mmm01.romBankMid
+	if mmm01.isMultiplexEnable {
+		mid = mmm01.ramBankLow
+	}
+	mask := mmm01.romBankMask << 1
+	outer := int(mid)<<5 | int(mmm01.romBankHigh)<<7
+
+	low := mmm01.romBankLow
+	if low&^mask == 0 { // Bank 0 of the game is not mapped to 4000~7FFF (like MBC1).
+		low |= 1
+	}
+	bank0 = outer | int(mmm01.romBankLow&mask)
+	if mmm01.isMultiplexEnable && mmm01.isMBC1Mode {
+		bank0 = int(mmm01.romBankHigh)<<7 | int(mmm01.romBankLow&mask)
+	}
+	return bank0 % romBanks, (outer | int(low)) % romBanks
+}
+
+func (mmm01 *MMM01) getRAMBank() int {
+	bank := int(mmm01.ramBankLow) | int(mmm01.ramBankHigh)<<2
+	if mmm01.isMultiplexEnable {
+		bank = int(mmm01.romBankMid) | int(mmm01.ramBankHigh)<<2
+	}
+	return bank % mmm01.totalRAMBanks
+}
+
+// Read from ROM in the current bank
+func (mmm01 *MMM01) ReadROM(addr uint16) byte {
+	bank0, bank := mmm01.getROMBanks()
+	switch {
+	case addr < 0x4000:
+		return mmm01.rom[bank0*0x4000+int(addr)]
+	case addr >= 0x4000 && addr < 0x8000:
+		return mmm01.rom[bank*0x4000+int(addr)-0x4000]
+	default:
+		return 0xFF
+	}
+}
+
+// Read from eram in the current bank
+func (mmm01 *MMM01) ReadERAM(addr uint16) byte {
+	if !mmm01.isRAMEnable || mmm01.totalRAMBanks == 0 {
+		return 0xFF
+	}
+	return mmm01.eram[mmm01.getRAMBank()*0x2000+int(addr)-0xA000]
+}
+
+// Write to ROM area
+// (it is not a write to the ROM, but a write to the MBC register)
+// Bits masked by the mask registers keep the value set by the menu.
+func (mmm01 *MMM01) WriteROM(addr uint16, val byte) {
+	switch {
+	case addr < 0x2000: // RAM Enable, RAM bank mask, Map enable
+		mmm01.isRAMEnable = val&0x0F == 0x0A
+		if !mmm01.isMapped {
+			mmm01.ramBankMask = val >> 4 & 0x03
+			mmm01.isMapped = val&(1<<6) != 0
+		}
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		mask := mmm01.romBankMask << 1
+		mmm01.romBankLow = mmm01.romBankLow&mask | val&^mask&0x1F
+		if !mmm01.isMapped {
+			mmm01.romBankMid = val >> 5 & 0x03
+		}
+	case addr >= 0x4000 && addr < 0x6000: // RAM Bank Number, outer ROM bank
+		mask := mmm01.ramBankMask
+		mmm01.ramBankLow = mmm01.ramBankLow&mask | val&^mask&0x03
+		if !mmm01.isMapped {
+			mmm01.ramBankHigh = val >> 2 & 0x03
+			mmm01.romBankHigh = val >> 4 & 0x03
+			mmm01.isMBC1ModeLocked = val&(1<<6) != 0
+		}
+	case addr >= 0x6000 && addr < 0x8000: // Banking mode, ROM bank mask
+		if !mmm01.isMBC1ModeLocked {
+			mmm01.isMBC1Mode = val&0x01 != 0
+		}
+		if !mmm01.isMapped {
+			mmm01.romBankMask = val >> 2 & 0x0F
+			mmm01.isMultiplexEnable = val&(1<<6) != 0
+		}
+	}
+}
+
+// Write to eram in the current bank
+func (mmm01 *MMM01) WriteERAM(addr uint16, val byte) {
+	if !mmm01.isRAMEnable || mmm01.totalRAMBanks == 0 {
+		return
+	}
+	mmm01.eram[mmm01.getRAMBank()*0x2000+int(addr)-0xA000] = val
+	mmm01.isDirty = true
+}
+
+func (mmm01 *MMM01) GetSaveData() []byte {
+	return mmm01.eram
+}
+
+func (mmm01 *MMM01) SaveState(e *savestate.Encoder) {
+	e.Write(mmm01.eram)
+	e.Write(mmm01.isRAMEnable)
+	e.Write(mmm01.isMapped)
+	e.Write([7]byte{
+		mmm01.romBankLow, mmm01.romBankMid, mmm01.romBankHigh, mmm01.romBankMask,
+		mmm01.ramBankLow, mmm01.ramBankHigh, mmm01.ramBankMask,
+	})
+	e.Write([3]bool{mmm01.isMBC1Mode, mmm01.isMBC1ModeLocked, mmm01.isMultiplexEnable})
+}
+
+func (mmm01 *MMM01) LoadState(d *savestate.Decoder) {
+	d.Read(mmm01.eram)
+	d.Read(&mmm01.isRAMEnable)
+	d.Read(&mmm01.isMapped)
+	var banks [7]byte
+	d.Read(&banks)
+	mmm01.romBankLow, mmm01.romBankMid, mmm01.romBankHigh, mmm01.romBankMask = banks[0], banks[1], banks[2], banks[3]
+	mmm01.ramBankLow, mmm01.ramBankHigh, mmm01.ramBankMask = banks[4], banks[5], banks[6]
+	d.Check(mmm01.romBankLow <= 0x1F && mmm01.romBankMid <= 0x03 && mmm01.romBankHigh <= 0x03 && mmm01.romBankMask <= 0x0F, "MMM01 ROM bank")
+	d.Check(mmm01.ramBankLow <= 0x03 && mmm01.ramBankHigh <= 0x03 && mmm01.ramBankMask <= 0x03, "MMM01 RAM bank")
+	var flags [3]bool
+	d.Read(&flags)
+	mmm01.isMBC1Mode, mmm01.isMBC1ModeLocked, mmm01.isMultiplexEnable = flags[0], flags[1], flags[2]
+}
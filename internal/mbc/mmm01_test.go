@@ -0,0 +1,98 @@
+package mbc
+
+import "testing"
+
+type romWrite struct {
+	addr uint16
+	val  byte
+}
+
+func TestMMM01ROMBanks(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []romWrite
+		wantBank0 byte // Bank at 0000~3FFF
+		wantBank  byte // Bank at 4000~7FFF
+	}{
+		{
+			name:      "menu (unmapped)",
+			wantBank0: 254, wantBank: 255,
+		},
+		{
+			name:      "writes before mapping do not change the menu",
+			writes:    []romWrite{{0x2000, 0x05}},
+			wantBank0: 254, wantBank: 255,
+		},
+		{
+			name:      "game at bank 32",
+			writes:    []romWrite{{0x2000, 0x20}, {0x0000, 0x40}},
+			wantBank0: 32, wantBank: 33,
+		},
+		{
+			name:      "game at bank 32, bank 5",
+			writes:    []romWrite{{0x2000, 0x20}, {0x0000, 0x40}, {0x2000, 0x05}},
+			wantBank0: 32, wantBank: 37,
+		},
+		{
+			name:      "outer bits are locked after mapping",
+			writes:    []romWrite{{0x2000, 0x20}, {0x0000, 0x40}, {0x2000, 0x65}, {0x4000, 0x30}},
+			wantBank0: 32, wantBank: 37,
+		},
+		{
+			name:      "game at bank 128 (4000 bit 4-5)",
+			writes:    []romWrite{{0x4000, 0x10}, {0x0000, 0x40}, {0x2000, 0x02}},
+			wantBank0: 128, wantBank: 130,
+		},
+		{
+			name: "masked bits keep the menu value",
+			writes: []romWrite{
+				{0x2000, 0x04}, {0x6000, 0x3C}, {0x0000, 0x40}, // Bank 1-4 are masked.
+				{0x2000, 0x1F},
+			},
+			wantBank0: 4, wantBank: 5,
+		},
+		{
+			name: "masked bank 0 is mapped as 1",
+			writes: []romWrite{
+				{0x2000, 0x04}, {0x6000, 0x3C}, {0x0000, 0x40},
+				{0x2000, 0x00},
+			},
+			wantBank0: 4, wantBank: 5,
+		},
+	}
+	for _, tt := range tests {
+		mmm01 := NewMMM01(newBankedROM(256), nil, 0)
+		for _, w := range tt.writes {
+			mmm01.WriteROM(w.addr, w.val)
+		}
+		if got := mmm01.ReadROM(0x0000); got != tt.wantBank0 {
+			t.Errorf("%s: bank at 0000 = %d, want %d", tt.name, got, tt.wantBank0)
+		}
+		if got := mmm01.ReadROM(0x4000); got != tt.wantBank {
+			t.Errorf("%s: bank at 4000 = %d, want %d", tt.name, got, tt.wantBank)
+		}
+	}
+}
+
+// The RAM bank bits masked by the menu keep the menu value.
+func TestMMM01RAMBanks(t *testing.T) {
+	mmm01 := NewMMM01(newBankedROM(128), nil, 4)
+	mmm01.WriteROM(0x4000, 0x02)           // RAM bank 2
+	mmm01.WriteROM(0x0000, 0x40|0x20|0x0A) // Mask bit 1, map, enable RAM
+	mmm01.WriteROM(0x4000, 0x01)           // Bit 1 is kept: bank 3
+	mmm01.WriteERAM(0xA000, 0x33)
+	if got := mmm01.eram[3*0x2000]; got != 0x33 {
+		t.Errorf("bank 3 = %02X, want 33", got)
+	}
+	if got := mmm01.ReadERAM(0xA000); got != 0x33 {
+		t.Errorf("read %02X, want 33", got)
+	}
+
+	mmm01.WriteROM(0x0000, 0x00) // Disable RAM (the mapping is kept)
+	if got := mmm01.ReadERAM(0xA000); got != 0xFF {
+		t.Errorf("disabled RAM reads %02X, want FF", got)
+	}
+	if got := mmm01.ReadROM(0x4000); got != 1 {
+		t.Errorf("bank at 4000 = %d after disabling RAM, want 1", got)
+	}
+}
@@ -0,0 +1,80 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+// The Sachen is the mapper of the unlicensed Sachen cartridges (MMC1).
+// The bits set in the mask register are taken from the base register,
+// so the base selects a game in multi-game cartridges.
+// The base and mask can be changed only while bit 4-5 of the ROM bank are set.
+//
+// The real cartridge starts in a "locked" mode that scrambles the header for the boot ROM.
+// It is not emulated, the cartridge starts unlocked.
+type Sachen struct {
+	dirtyFlag
+
+	rom     []byte // =.gb data
+	romBank byte
+	base    byte
+	mask    byte
+}
+
+func NewSachen(rom []byte) *Sachen {
+	return &Sachen{
+		rom:     rom,
+		romBank: 1,
+	}
+}
+
+// Read from ROM in the current bank
+func (sachen *Sachen) ReadROM(addr uint16) byte {
+	var bank byte
+	switch {
+	case addr < 0x4000:
+		bank = sachen.base & sachen.mask
+	case addr >= 0x4000 && addr < 0x8000:
+		bank = sachen.romBank&^sachen.mask | sachen.base&sachen.mask
+	default:
+		return 0xFF
+	}
+	offset := int(bank)*0x4000 + int(addr&0x3FFF)
+	return sachen.rom[offset%len(sachen.rom)]
+}
+
+// No RAM
+func (sachen *Sachen) ReadERAM(addr uint16) byte {
+	return 0xFF
+}
+
+func (sachen *Sachen) WriteROM(addr uint16, val byte) {
+	isUnlocked := sachen.romBank&0x30 == 0x30
+	switch {
+	case addr < 0x2000: // Base ROM Bank
+		if isUnlocked {
+			sachen.base = val
+		}
+	case addr >= 0x2000 && addr < 0x4000: // ROM Bank Number
+		sachen.romBank = max(val, 1)
+	case addr >= 0x4000 && addr < 0x6000: // ROM Bank Mask
+		if isUnlocked {
+			sachen.mask = val
+		}
+	}
+}
+
+func (sachen *Sachen) WriteERAM(addr uint16, val byte) {
+}
+
+func (sachen *Sachen) GetSaveData() []byte {
+	return nil
+}
+
+func (sachen *Sachen) SaveState(e *savestate.Encoder) {
+	e.Write([3]byte{sachen.romBank, sachen.base, sachen.mask})
+}
+
+func (sachen *Sachen) LoadState(d *savestate.Decoder) {
+	var regs [3]byte
+	d.Read(&regs)
+	sachen.romBank, sachen.base, sachen.mask = regs[0], regs[1], regs[2]
+	d.Check(sachen.romBank >= 1, "Sachen ROM bank")
+}
@@ -0,0 +1,51 @@
+package mbc
+
+import "testing"
+
+func TestSachenROMBanks(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []romWrite
+		wantBank0 byte
+		wantBank  byte
+	}{
+		{name: "reset", wantBank0: 0, wantBank: 1},
+		{name: "bank 5", writes: []romWrite{{0x2000, 0x05}}, wantBank0: 0, wantBank: 5},
+		{name: "bank 0 is bank 1", writes: []romWrite{{0x2000, 0x00}}, wantBank0: 0, wantBank: 1},
+		{
+			name:      "base and mask are locked",
+			writes:    []romWrite{{0x0000, 0x10}, {0x4000, 0x30}, {0x2000, 0x02}},
+			wantBank0: 0, wantBank: 2,
+		},
+		{
+			name:      "game at bank $10",
+			writes:    []romWrite{{0x2000, 0x30}, {0x0000, 0x10}, {0x4000, 0x30}, {0x2000, 0x02}},
+			wantBank0: 0x10, wantBank: 0x12,
+		},
+		{
+			name: "base is locked again",
+			writes: []romWrite{
+				{0x2000, 0x30}, {0x0000, 0x10}, {0x4000, 0x30}, {0x2000, 0x02},
+				{0x0000, 0x00}, {0x4000, 0x00},
+			},
+			wantBank0: 0x10, wantBank: 0x12,
+		},
+		{
+			name:      "masked bits come from the base",
+			writes:    []romWrite{{0x2000, 0x30}, {0x0000, 0x10}, {0x4000, 0x30}, {0x2000, 0x23}},
+			wantBank0: 0x10, wantBank: 0x13,
+		},
+	}
+	for _, tt := range tests {
+		sachen := NewSachen(newBankedROM(64))
+		for _, w := range tt.writes {
+			sachen.WriteROM(w.addr, w.val)
+		}
+		if got := sachen.ReadROM(0x0000); got != tt.wantBank0 {
+			t.Errorf("%s: bank at 0000 = %#x, want %#x", tt.name, got, tt.wantBank0)
+		}
+		if got := sachen.ReadROM(0x4000); got != tt.wantBank {
+			t.Errorf("%s: bank at 4000 = %#x, want %#x", tt.name, got, tt.wantBank)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mbc
+
+import "gomeboy/internal/savestate"
+
+// The WisdomTree is the mapper of the unlicensed Wisdom Tree cartridges.
+// A write to 0000~3FFF maps the 32KiB bank selected by the lower bits of the address
+// (not the value) to 0000~7FFF. The header says "ROM ONLY".
+type WisdomTree struct {
+	dirtyFlag
+
+	rom  []byte // =.gb data
+	bank int    // 32KiB bank
+}
+
+func NewWisdomTree(rom []byte) *WisdomTree {
+	return &WisdomTree{rom: rom}
+}
+
+// Read from ROM in the current 32KiB bank
+func (wt *WisdomTree) ReadROM(addr uint16) byte {
+	if addr >= 0x8000 {
+		return 0xFF
+	}
+	offset := wt.bank*0x8000 + int(addr)
+	return wt.rom[offset%len(wt.rom)]
+}
+
+// No RAM
+func (wt *WisdomTree) ReadERAM(addr uint16) byte {
+	return 0xFF
+}
+
+func (wt *WisdomTree) WriteROM(addr uint16, val byte) {
+	if addr < 0x4000 {
+		wt.bank = int(addr & 0x3F)
+	}
+}
+
+func (wt *WisdomTree) WriteERAM(addr uint16, val byte) {
+}
+
+func (wt *WisdomTree) GetSaveData() []byte {
+	return nil
+}
+
+func (wt *WisdomTree) SaveState(e *savestate.Encoder) {
+	e.WriteInt(wt.bank)
+}
+
+func (wt *WisdomTree) LoadState(d *savestate.Decoder) {
+	d.ReadInt(&wt.bank)
+	d.Check(wt.bank >= 0 && wt.bank <= 0x3F, "WisdomTree bank")
+}
@@ -0,0 +1,32 @@
+package mbc
+
+import "testing"
+
+// The address (not the value) written to 0000~3FFF selects a 32KiB bank.
+func TestWisdomTreeROMBanks(t *testing.T) {
+	tests := []struct {
+		name      string
+		writes    []romWrite
+		wantBank0 byte // 16KiB bank at 0000~3FFF
+		wantBank  byte // 16KiB bank at 4000~7FFF
+	}{
+		{name: "reset", wantBank0: 0, wantBank: 1},
+		{name: "bank 2", writes: []romWrite{{0x0002, 0x00}}, wantBank0: 4, wantBank: 5},
+		{name: "the value is ignored", writes: []romWrite{{0x0001, 0x03}}, wantBank0: 2, wantBank: 3},
+		{name: "upper address bits are ignored", writes: []romWrite{{0x3F43, 0x00}}, wantBank0: 6, wantBank: 7},
+		{name: "4000~7FFF are not registers", writes: []romWrite{{0x4002, 0x00}}, wantBank0: 0, wantBank: 1},
+		{name: "wraps around the ROM", writes: []romWrite{{0x0009, 0x00}}, wantBank0: 2, wantBank: 3},
+	}
+	for _, tt := range tests {
+		wt := NewWisdomTree(newBankedROM(16))
+		for _, w := range tt.writes {
+			wt.WriteROM(w.addr, w.val)
+		}
+		if got := wt.ReadROM(0x0000); got != tt.wantBank0 {
+			t.Errorf("%s: bank at 0000 = %d, want %d", tt.name, got, tt.wantBank0)
+		}
+		if got := wt.ReadROM(0x4000); got != tt.wantBank {
+			t.Errorf("%s: bank at 4000 = %d, want %d", tt.name, got, tt.wantBank)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"gomeboy/internal/mbc"
+)
+
+// The smallest cartridge is 32KiB (2 banks without an MBC).
+const MinROMSize = 0x8000
+
+var ErrROMTooSmall = errors.New("ROM is too small (less than 32KiB)")
+
+// The UnsupportedMapperError is returned for a cartridge type (0147) or a mapper name without an implementation.
+type UnsupportedMapperError struct {
+	CartType byte
+	Name     string // Set if the mapper was overridden by name
+}
+
+func (err *UnsupportedMapperError) Error() string {
+	if err.Name != "" {
+		return fmt.Sprintf("unsupported mapper %q", err.Name)
+	}
+	return fmt.Sprintf("unsupported mapper (cartridge type 0x%02X)", err.CartType)
+}
+
+// The HeaderSizeMismatchError is returned when the ROM size in the header (0148)
+// is larger than the file. (e.g. a truncated dump)
+type HeaderSizeMismatchError struct {
+	HeaderSize int
+	FileSize   int
+}
+
+func (err *HeaderSizeMismatchError) Error() string {
+	return fmt.Sprintf("ROM size in the header is %d bytes, but the file is %d bytes", err.HeaderSize, err.FileSize)
+}
+
+// The BootROMSizeError is returned for a boot ROM that is neither DMG (256 Bytes) nor CGB (2304 Bytes).
+type BootROMSizeError struct {
+	Size int
+}
+
+func (err *BootROMSizeError) Error() string {
+	return fmt.Sprintf("invalid boot ROM size: %d bytes", err.Size)
+}
+
+// The SaveSizeError is returned for a .sav file with an unknown footer (see mbc.SaveSizeError).
+type SaveSizeError = mbc.SaveSizeError
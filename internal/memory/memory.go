@@ -2,6 +2,8 @@ package memory
 
 import (
 	"fmt"
+	"gomeboy/internal/camera"
+	"gomeboy/internal/cartridge"
 	"gomeboy/internal/mbc"
 )
 
@@ -14,35 +16,131 @@ type Memory struct {
 
 	wramBank byte // only CGB mode
 
+	// Boot ROM (overlaid on the cartridge ROM until FF50 is written)
+	bootROM         []byte
+	isBootROMMapped bool
+
 	// Cartridge Header
+	Header        *cartridge.Header
 	mbcType       int
 	TotalROMBanks int
 	TotalRAMBanks int
 }
 
-func NewMemory(rom, sav []byte) *Memory {
-	mem := &Memory{}
+// The mapper overrides the mapper detected from the cartridge header (see mbc.MapperNames).
+func NewMemory(rom, sav []byte, mapper string) (*Memory, error) {
+	if len(rom) < MinROMSize {
+		return nil, ErrROMTooSmall
+	}
+	header, err := cartridge.Parse(rom)
+	if err != nil {
+		return nil, err
+	}
+	mem := &Memory{Header: header}
 	mbc.InitLists()
-	mem.mbcType = mbc.MBCTypeList[rom[0x0147]]
-	mem.TotalROMBanks = mbc.TotalROMBanksList[rom[0x0148]]
-	mem.TotalRAMBanks = mbc.TotalRAMBanksList[rom[0x0149]]
+	if mapper != "" {
+		t, ok := mbc.MapperNames[mapper]
+		if !ok {
+			return nil, &UnsupportedMapperError{CartType: header.CartType, Name: mapper}
+		}
+		mem.mbcType = t
+	} else {
+		mem.mbcType = mbc.DetectMapper(rom)
+	}
+	mem.TotalROMBanks = header.ROMBanks()
+	mem.TotalRAMBanks = header.RAMBanks()
+
+	// The header of multi-game and unlicensed cartridges is not for the whole ROM.
+	isUnlicensed := mem.mbcType >= mbc.TypeWisdomTree
+	isHeaderReliable := mapper == "" && mem.mbcType != mbc.TypeMMM01 && !isUnlicensed
+	if isHeaderReliable && mem.TotalROMBanks*0x4000 > len(rom) {
+		return nil, &HeaderSizeMismatchError{HeaderSize: mem.TotalROMBanks * 0x4000, FileSize: len(rom)}
+	}
+
+	ramBanks := max(mem.TotalRAMBanks, 0) // Unknown size = No RAM
 	switch mem.mbcType {
 	case 0:
-		mem.mbc = mbc.NewMBC0(rom, sav, mem.TotalRAMBanks) // = No MBC
+		mem.mbc = mbc.NewMBC0(rom, sav, ramBanks) // = No MBC
 	case 1:
-		mem.mbc = mbc.NewMBC1(rom, sav, mem.TotalRAMBanks)
+		mem.mbc = mbc.NewMBC1(rom, sav, ramBanks)
+	case 2:
+		mem.mbc = mbc.NewMBC2(rom, sav)
+	case 3:
+		hasRTC := header.CartType == 0x0F || header.CartType == 0x10
+		mem.mbc, err = mbc.NewMBC3(rom, sav, ramBanks, hasRTC)
 	case 5:
-		mem.mbc = mbc.NewMBC5(rom, sav, mem.TotalRAMBanks)
+		hasRumble := header.CartType >= 0x1C && header.CartType <= 0x1E
+		mem.mbc = mbc.NewMBC5(rom, sav, ramBanks, hasRumble)
+	case 7:
+		mem.mbc, err = mbc.NewMBC7(rom, sav)
+	case mbc.TypeHuC1:
+		mem.mbc = mbc.NewHuC1(rom, sav, ramBanks)
+	case mbc.TypeHuC3:
+		mem.mbc, err = mbc.NewHuC3(rom, sav, ramBanks)
+	case mbc.TypeMMM01:
+		// The RAM size in the header of the menu (the last 32KiB) is for all games.
+		menu, _ := cartridge.Parse(rom[len(rom)-0x8000:])
+		mem.TotalRAMBanks = max(ramBanks, menu.RAMBanks())
+		mem.mbc = mbc.NewMMM01(rom, sav, mem.TotalRAMBanks)
+	case mbc.TypeCamera:
+		mem.mbc = mbc.NewPocketCamera(rom, sav, ramBanks)
+	case mbc.TypeWisdomTree:
+		mem.mbc = mbc.NewWisdomTree(rom)
+	case mbc.TypeM161:
+		mem.mbc = mbc.NewM161(rom)
+	case mbc.TypeSachen:
+		mem.mbc = mbc.NewSachen(rom)
 	default:
-		panic("Unsupported MBC type")
+		return nil, &UnsupportedMapperError{CartType: header.CartType}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mem, nil
+}
+
+// The Step drives the clock of the cartridge (if it has one).
+func (m *Memory) Step(cycles int) {
+	if t, ok := m.mbc.(mbc.Ticker); ok {
+		t.Step(cycles)
+	}
+}
+
+// The SetRumbleHandler returns false if the cartridge has no rumble motor.
+func (m *Memory) SetRumbleHandler(fn func(isOn bool)) bool {
+	r, ok := m.mbc.(mbc.Rumbler)
+	if !ok || !r.HasRumble() {
+		return false
 	}
-	return mem
+	r.SetRumbleHandler(fn)
+	return true
+}
+
+// The SetAcceleration returns false if the cartridge has no accelerometer.
+func (m *Memory) SetAcceleration(x, y float64) bool {
+	a, ok := m.mbc.(mbc.Accelerometer)
+	if ok {
+		a.SetAcceleration(x, y)
+	}
+	return ok
+}
+
+// The SetImageSource returns false if the cartridge has no image sensor.
+func (m *Memory) SetImageSource(src camera.Source) bool {
+	s, ok := m.mbc.(mbc.ImageSensor)
+	if ok {
+		s.SetImageSource(src)
+	}
+	return ok
 }
 
 // Called from Bus.Read()
 func (m *Memory) Read(addr uint16) byte {
 	switch {
 	case addr < 0x8000:
+		if m.isBootROMArea(addr) {
+			return m.bootROM[addr]
+		}
 		return m.mbc.ReadROM(addr)
 
 	case addr >= 0x8000 && addr < 0xA000:
@@ -118,6 +216,30 @@ func (m *Memory) Write(addr uint16, val byte) {
 	}
 }
 
+// The SetBootROM maps the DMG (256 Bytes) or CGB (2304 Bytes) boot ROM.
+func (m *Memory) SetBootROM(boot []byte) error {
+	if len(boot) != 0x100 && len(boot) != 0x900 {
+		return &BootROMSizeError{Size: len(boot)}
+	}
+	m.bootROM = boot
+	m.isBootROMMapped = true
+	return nil
+}
+
+// Writing to FF50 unmaps the boot ROM until the next reset.
+func (m *Memory) UnmapBootROM() {
+	m.isBootROMMapped = false
+}
+
+// The boot ROM is mapped to 0000~00FF,
+// and the CGB boot ROM is also mapped to 0200~08FF (0100~01FF is the cartridge header).
+func (m *Memory) isBootROMArea(addr uint16) bool {
+	if !m.isBootROMMapped {
+		return false
+	}
+	return addr < 0x100 || (addr >= 0x200 && int(addr) < len(m.bootROM))
+}
+
 func (m *Memory) ReadWRAMBank() byte {
 	return m.wramBank & 0x07
 }
@@ -127,14 +249,28 @@ func (m *Memory) WriteWRAMBank(val byte) {
 }
 
 func (m *Memory) GetHeaderInfo() []string {
-	var mbc string
+	var mbcName string
 	switch m.mbcType {
 	case -1:
-		mbc = "Unsupported"
+		mbcName = "Unsupported"
 	case 0:
-		mbc = "No MBC"
+		mbcName = "No MBC"
+	case mbc.TypeHuC1:
+		mbcName = "HuC1"
+	case mbc.TypeHuC3:
+		mbcName = "HuC3"
+	case mbc.TypeMMM01:
+		mbcName = "MMM01"
+	case mbc.TypeCamera:
+		mbcName = "Pocket Camera"
+	case mbc.TypeWisdomTree:
+		mbcName = "Wisdom Tree"
+	case mbc.TypeM161:
+		mbcName = "M161"
+	case mbc.TypeSachen:
+		mbcName = "Sachen"
 	default:
-		mbc = fmt.Sprint(m.mbcType)
+		mbcName = fmt.Sprint(m.mbcType)
 	}
 
 	var rom string
@@ -151,13 +287,31 @@ func (m *Memory) GetHeaderInfo() []string {
 		ram = fmt.Sprintf("%d banks", m.TotalRAMBanks)
 	}
 
+	checksum := "OK"
+	switch {
+	case !m.Header.IsHeaderChecksumValid:
+		checksum = "NG(Header)"
+	case !m.Header.IsGlobalChecksumValid:
+		checksum = "NG(Global)"
+	}
+
 	var strs []string
-	strs = append(strs, "MBC:"+mbc)
+	strs = append(strs, "MBC:"+mbcName)
 	strs = append(strs, "ROM:"+rom)
 	strs = append(strs, "RAM:"+ram)
+	strs = append(strs, "SUM:"+checksum)
 	return strs
 }
 
 func (m *Memory) GetSaveData() []byte {
 	return m.mbc.GetSaveData()
 }
+
+// The IsSaveDirty reports whether the save data has been written since ClearSaveDirty.
+func (m *Memory) IsSaveDirty() bool {
+	return m.mbc.IsDirty()
+}
+
+func (m *Memory) ClearSaveDirty() {
+	m.mbc.ClearDirty()
+}
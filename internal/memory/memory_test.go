@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+)
+
+// The newTestROM returns a 32KiB ROM with the cartridge type in the header.
+func newTestROM(cartType byte) []byte {
+	rom := make([]byte, MinROMSize)
+	copy(rom[0x134:], "TEST")
+	rom[0x147] = cartType
+	return rom
+}
+
+// Only MBC5+RUMBLE cartridges (1C~1E) take the rumble handler.
+func TestSetRumbleHandler(t *testing.T) {
+	tests := []struct {
+		cartType byte
+		want     bool
+	}{
+		{0x01, false}, // MBC1
+		{0x19, false}, // MBC5
+		{0x1A, false}, // MBC5+RAM
+		{0x1B, false}, // MBC5+RAM+BATTERY
+		{0x1C, true},  // MBC5+RUMBLE
+		{0x1D, true},  // MBC5+RUMBLE+RAM
+		{0x1E, true},  // MBC5+RUMBLE+RAM+BATTERY
+	}
+	for _, tt := range tests {
+		m, err := NewMemory(newTestROM(tt.cartType), nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.SetRumbleHandler(func(bool) {}); got != tt.want {
+			t.Errorf("cartridge type %02X: SetRumbleHandler = %v, want %v", tt.cartType, got, tt.want)
+		}
+	}
+}
+
+func TestNewMemoryErrors(t *testing.T) {
+	withHeader := func(cartType, romSize, ramSize byte) []byte {
+		rom := newTestROM(cartType)
+		rom[0x148] = romSize
+		rom[0x149] = ramSize
+		return rom
+	}
+	tests := []struct {
+		name   string
+		rom    []byte
+		sav    []byte
+		mapper string
+		check  func(err error) bool
+	}{
+		{
+			name:  "ROM too small",
+			rom:   make([]byte, MinROMSize-1),
+			check: func(err error) bool { return errors.Is(err, ErrROMTooSmall) },
+		},
+		{
+			name: "unsupported cartridge type",
+			rom:  newTestROM(0x20), // MBC6
+			check: func(err error) bool {
+				var e *UnsupportedMapperError
+				return errors.As(err, &e) && e.CartType == 0x20 && e.Name == ""
+			},
+		},
+		{
+			name:   "unknown mapper name",
+			rom:    newTestROM(0x00),
+			mapper: "mbc6",
+			check: func(err error) bool {
+				var e *UnsupportedMapperError
+				return errors.As(err, &e) && e.Name == "mbc6"
+			},
+		},
+		{
+			name: "header larger than the file",
+			rom:  withHeader(0x01, 0x02, 0x00), // 128KiB
+			check: func(err error) bool {
+				var e *HeaderSizeMismatchError
+				return errors.As(err, &e) && e.HeaderSize == 0x20000 && e.FileSize == 0x8000
+			},
+		},
+		{
+			name:   "header larger than the file with a mapper override",
+			rom:    withHeader(0x01, 0x02, 0x00),
+			mapper: "mbc1",
+			check:  func(err error) bool { return err == nil },
+		},
+		{
+			name: "MBC3 save with an unknown footer",
+			rom:  withHeader(0x13, 0x00, 0x02), // MBC3+RAM+BATTERY, 8KiB
+			sav:  make([]byte, 0x2000+5),
+			check: func(err error) bool {
+				var e *SaveSizeError
+				return errors.As(err, &e) && e.Size == 0x2000+5 && e.RAMSize == 0x2000
+			},
+		},
+		{
+			name:  "MBC3 save with the RTC footer",
+			rom:   withHeader(0x10, 0x00, 0x02), // MBC3+TIMER+RAM+BATTERY
+			sav:   make([]byte, 0x2000+48),
+			check: func(err error) bool { return err == nil },
+		},
+		{
+			name: "MBC7 save larger than the EEPROM",
+			rom:  newTestROM(0x22),
+			sav:  make([]byte, 300),
+			check: func(err error) bool {
+				var e *SaveSizeError
+				return errors.As(err, &e) && e.Size == 300
+			},
+		},
+	}
+	for _, tt := range tests {
+		_, err := NewMemory(tt.rom, tt.sav, tt.mapper)
+		if !tt.check(err) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
+
+func TestSetBootROM(t *testing.T) {
+	tests := []struct {
+		size int
+		ok   bool
+	}{
+		{0x100, true}, // DMG
+		{0x900, true}, // CGB
+		{0, false},
+		{0x200, false},
+		{0x800, false},
+	}
+	for _, tt := range tests {
+		m, err := NewMemory(newTestROM(0x00), nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.SetBootROM(make([]byte, tt.size))
+		if tt.ok {
+			if err != nil {
+				t.Errorf("size %#x: %v", tt.size, err)
+			}
+			continue
+		}
+		var e *BootROMSizeError
+		if !errors.As(err, &e) || e.Size != tt.size {
+			t.Errorf("size %#x: err = %v, want BootROMSizeError", tt.size, err)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package memory
+
+import "gomeboy/internal/savestate"
+
+func (m *Memory) SaveState(e *savestate.Encoder) {
+	e.Write(&m.wram)
+	e.Write(&m.hram)
+	e.Write(&m.io)
+	e.Write(m.ie)
+	e.Write(m.wramBank)
+	e.Write(m.isBootROMMapped)
+	m.mbc.SaveState(e)
+}
+
+func (m *Memory) LoadState(d *savestate.Decoder) {
+	d.Read(&m.wram)
+	d.Read(&m.hram)
+	d.Read(&m.io)
+	d.Read(&m.ie)
+	d.Read(&m.wramBank)
+	d.Check(m.wramBank <= 7, "WRAM bank")
+	d.Read(&m.isBootROMMapped)
+	m.isBootROMMapped = m.isBootROMMapped && m.bootROM != nil
+	m.mbc.LoadState(d)
+}
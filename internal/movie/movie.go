@@ -0,0 +1,127 @@
+// Package movie reads and writes the joypad input of each frame,
+// so a play session can be replayed exactly (bug reports, regression tests).
+// Other inputs from the host (the tilt of MBC7, the camera image) are not recorded.
+//
+// File layout (little-endian):
+//
+//	"GMBM", Version (uint32), ROM CRC32 (uint32), boot ROM CRC32 (uint32, 0 = none),
+//	save state size (int64, 0 = power-on) + save state,
+//	frame count (int64) + buttons of each frame (1 byte, joypad.ButtonXX, Pressed=1)
+
+package movie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gomeboy/internal/savestate"
+	"io"
+	"os"
+)
+
+// Version must be incremented whenever the layout of the file changes.
+const Version uint32 = 1
+
+var magic = [4]byte{'G', 'M', 'B', 'M'}
+
+var ErrNotMovie = errors.New("not a GOmeBoy movie")
+
+// The VersionError is returned when a movie was made by another version of the format.
+type VersionError struct {
+	Version uint32
+}
+
+func (err *VersionError) Error() string {
+	return fmt.Sprintf("unsupported movie version %d (expected %d)", err.Version, Version)
+}
+
+type Movie struct {
+	ROMChecksum     uint32 // CRC32 of the ROM
+	BootROMChecksum uint32 // CRC32 of the boot ROM run at power-on (0 = no boot ROM)
+	State           []byte // Save state to start from (nil = power-on without the .sav file)
+	Frames          []byte // Buttons of each frame
+}
+
+// The IsFromPowerOn reports whether the movie starts from power-on (not from a save state).
+func (m *Movie) IsFromPowerOn() bool {
+	return len(m.State) == 0
+}
+
+func (m *Movie) Write(w io.Writer) error {
+	enc := savestate.NewEncoder(w)
+	enc.Write(magic)
+	enc.Write(Version)
+	enc.Write(m.ROMChecksum)
+	enc.Write(m.BootROMChecksum)
+	enc.WriteInt(len(m.State))
+	enc.Write(m.State)
+	enc.WriteInt(len(m.Frames))
+	enc.Write(m.Frames)
+	return enc.Err()
+}
+
+func Read(r io.Reader) (*Movie, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	src := bytes.NewReader(data)
+	dec := savestate.NewDecoder(src)
+
+	var mg [4]byte
+	var version uint32
+	dec.Read(&mg)
+	dec.Read(&version)
+	switch {
+	case dec.Err() != nil || mg != magic:
+		return nil, ErrNotMovie
+	case version != Version:
+		return nil, &VersionError{Version: version}
+	}
+
+	m := &Movie{}
+	dec.Read(&m.ROMChecksum)
+	dec.Read(&m.BootROMChecksum)
+	if m.State, err = readBytes(dec, src); err != nil {
+		return nil, err
+	}
+	if m.Frames, err = readBytes(dec, src); err != nil {
+		return nil, err
+	}
+	if dec.Err() != nil || src.Len() != 0 {
+		return nil, ErrNotMovie
+	}
+	return m, nil
+}
+
+// The readBytes reads a size and the bytes, checking the size against the rest of the data.
+func readBytes(dec *savestate.Decoder, src *bytes.Reader) ([]byte, error) {
+	var n int
+	dec.ReadInt(&n)
+	if dec.Err() != nil || n < 0 || n > src.Len() {
+		return nil, ErrNotMovie
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	b := make([]byte, n)
+	dec.Read(b)
+	return b, dec.Err()
+}
+
+func Load(path string) (*Movie, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func (m *Movie) Save(path string) error {
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,381 @@
+package ppu
+
+import (
+	"gomeboy/internal/savestate"
+	"image/color"
+)
+
+// The RendererFIFO draws mode 3 dot by dot.
+// Mode 3 pushes the tiles fetched by the fetcher to the background FIFO,
+// mixes the objects in the object FIFO, and outputs a pixel per dot,
+// so its length depends on SCX, the window and the objects like the real hardware.
+
+// Dots before the fetcher starts at the start of mode 3
+// (The first tile fetch is discarded, so the shortest mode 3 takes 172 dots.)
+const fifoStartDelay = 7
+
+type fifoPixel struct {
+	colorIndex byte
+	palette    int
+	isPriority bool // BG: CGB BG map attribute bit 7, OBJ: OBJ-to-BG priority
+	oamIndex   byte // OBJ only
+}
+
+type pixelFIFO struct {
+	pixels [16]fifoPixel
+	head   int
+	size   int
+}
+
+func (q *pixelFIFO) push(px fifoPixel) {
+	q.pixels[(q.head+q.size)%len(q.pixels)] = px
+	q.size++
+}
+
+func (q *pixelFIFO) pop() fifoPixel {
+	px := q.pixels[q.head]
+	q.head = (q.head + 1) % len(q.pixels)
+	q.size--
+	return px
+}
+
+// The at returns the i-th pixel from the head.
+func (q *pixelFIFO) at(i int) *fifoPixel {
+	return &q.pixels[(q.head+i)%len(q.pixels)]
+}
+
+func (q *pixelFIFO) clear() {
+	q.head = 0
+	q.size = 0
+}
+
+type fifoRenderer struct {
+	bg  pixelFIFO
+	obj pixelFIFO
+
+	// Background/window fetcher
+	fetchStep int  // Dots since the fetch started (a tile takes 6 dots, then waits for the FIFO)
+	fetchX    int  // Tile column to fetch, counted from the left of the screen or the window
+	tileIndex byte //
+	tileAttr  byte // CGB mode only
+	tileData  [2]byte
+
+	// Object fetch
+	objectFetch     int // Index in PPU.scannedObjectList being fetched (-1 = none)
+	objectFetchStep int
+	isObjectFetched [10]bool
+
+	x             int // Pixels output on the current line
+	discard       int // Pixels to drop before the first one (SCX fine scroll, WX < 7)
+	delay         int // Dots left before the fetcher starts
+	isWindow      bool
+	isWindowDrawn bool // The window has been drawn on the current line
+	isWYTriggered bool // LY has matched WY in the current frame
+}
+
+func (p *PPU) startPixelTransfer() {
+	f := &p.fifo
+	f.bg.clear()
+	f.obj.clear()
+	f.fetchStep = 0
+	f.fetchX = 0
+	f.objectFetch = -1
+	f.isObjectFetched = [10]bool{}
+	f.x = 0
+	f.discard = int(p.scx & 0x07)
+	f.delay = fifoStartDelay
+	f.isWindow = false
+	f.isWindowDrawn = false
+}
+
+// The stepPixelTransfer runs a dot of mode 3. It returns true when the line is done.
+func (p *PPU) stepPixelTransfer() bool {
+	f := &p.fifo
+	if f.delay > 0 {
+		f.delay--
+		return false
+	}
+
+	if f.objectFetch >= 0 {
+		p.stepObjectFetch()
+		return false
+	}
+
+	if p.shouldStartWindow() {
+		f.bg.clear()
+		f.fetchStep = 0
+		f.fetchX = 0
+		f.isWindow = true
+		f.isWindowDrawn = true
+		f.discard = max(7-int(p.wx), 0)
+		return false
+	}
+
+	if i := p.findObjectToFetch(); i >= 0 {
+		f.objectFetch = i
+		f.objectFetchStep = 0
+		p.stepObjectFetch()
+		return false
+	}
+
+	p.stepBGFetcher()
+
+	if f.bg.size == 0 {
+		return false
+	}
+	bgPixel := f.bg.pop()
+	if f.discard > 0 {
+		f.discard--
+		return false
+	}
+	var objPixel fifoPixel
+	if f.obj.size > 0 {
+		objPixel = f.obj.pop()
+	}
+	p.fifoPixels[int(p.ly)*160+f.x] = p.mixPixels(bgPixel, objPixel)
+	f.x++
+	return f.x == 160
+}
+
+// The window starts when the pixel at WX-7 is output, once LY has matched WY in the frame.
+func (p *PPU) shouldStartWindow() bool {
+	f := &p.fifo
+	isWindowEnableBitSet := p.lcdc&(1<<5) != 0
+	return !f.isWindow && isWindowEnableBitSet && f.isWYTriggered &&
+		p.wx < 167 && f.x+7 >= int(p.wx)
+}
+
+// The stepBGFetcher reads the tile number, the low byte and the high byte (2 dots each),
+// then pushes 8 pixels when the background FIFO is empty.
+func (p *PPU) stepBGFetcher() {
+	f := &p.fifo
+	f.fetchStep++
+	switch f.fetchStep {
+	case 2:
+		mapAddress := p.getFetcherMapAddress()
+		f.tileIndex = p.vram[0][mapAddress]
+		if p.IsCGB {
+			f.tileAttr = p.vram[1][mapAddress]
+		}
+	case 4:
+		f.tileData[0] = p.vram[p.getFetcherTileBank()][p.getFetcherTileAddress()]
+	case 6:
+		f.tileData[1] = p.vram[p.getFetcherTileBank()][p.getFetcherTileAddress()+1]
+	}
+	if f.fetchStep < 6 || f.bg.size != 0 {
+		return
+	}
+
+	palette := DMG_BGP
+	isXFlip := false
+	isCGBBGMapPriorityBitSet := false
+	if p.IsCGB {
+		palette = CGB_BGP0 + int(f.tileAttr&0x07)
+		isXFlip = f.tileAttr&(1<<5) != 0
+		isCGBBGMapPriorityBitSet = f.tileAttr&(1<<7) != 0
+	}
+	for b := 0; b < 8; b++ {
+		bit := 7 - b
+		if isXFlip {
+			bit = b
+		}
+		lo := f.tileData[0] >> bit & 1
+		hi := f.tileData[1] >> bit & 1
+		f.bg.push(fifoPixel{
+			colorIndex: hi<<1 | lo,
+			palette:    palette,
+			isPriority: isCGBBGMapPriorityBitSet,
+		})
+	}
+	f.fetchStep = 0
+	f.fetchX++
+}
+
+func (p *PPU) getFetcherMapAddress() uint16 {
+	f := &p.fifo
+	if f.isWindow {
+		return p.getMapAddress(Window, (p.wly/8)*32+f.fetchX&0x1F)
+	}
+	mapRow := int(p.ly+p.scy) / 8
+	mapCol := (int(p.scx)/8 + f.fetchX) & 0x1F
+	return p.getMapAddress(Background, mapRow*32+mapCol)
+}
+
+func (p *PPU) getFetcherTileBank() int {
+	if p.IsCGB && p.fifo.tileAttr&(1<<3) != 0 {
+		return 1
+	}
+	return 0
+}
+
+func (p *PPU) getFetcherTileAddress() uint16 {
+	f := &p.fifo
+	tilePixelY := int(p.ly+p.scy) % 8
+	if f.isWindow {
+		tilePixelY = p.wly % 8
+	}
+	if p.IsCGB && f.tileAttr&(1<<6) != 0 { // Y flip
+		tilePixelY = 7 - tilePixelY
+	}
+	var tileStart uint16
+	if p.lcdc&(1<<4) != 0 { // Get tile data area
+		tileStart = uint16(f.tileIndex) << 4
+	} else {
+		tileStart = uint16(0x1000 + int(int8(f.tileIndex))<<4)
+	}
+	return tileStart + uint16(tilePixelY*2)
+}
+
+// The findObjectToFetch returns the first object (in OAM order) that starts at the current pixel,
+// or -1 if there is none.
+func (p *PPU) findObjectToFetch() int {
+	f := &p.fifo
+	if p.lcdc&(1<<1) == 0 {
+		return -1
+	}
+	for i, oamIndex := range p.scannedObjectList {
+		objectX := int(p.oam[oamIndex<<2+1])
+		if !f.isObjectFetched[i] && objectX <= f.x+8 {
+			return i
+		}
+	}
+	return -1
+}
+
+// The stepObjectFetch waits for the background fetcher to finish the current tile,
+// then fetches the object in 6 dots and mixes it into the object FIFO.
+func (p *PPU) stepObjectFetch() {
+	f := &p.fifo
+	if f.fetchStep < 4 || f.bg.size == 0 {
+		p.stepBGFetcher()
+		return
+	}
+	f.objectFetchStep++
+	if f.objectFetchStep < 6 {
+		return
+	}
+
+	oamIndex := p.scannedObjectList[f.objectFetch]
+	baseAdress := oamIndex << 2
+	objectY0 := int(p.oam[baseAdress]) - 16
+	objectX := int(p.oam[baseAdress+1])
+	tileIndex := int(p.oam[baseAdress+2])
+	objectAttributes := p.oam[baseAdress+3]
+	tileData := p.getObjectTile(tileIndex, objectAttributes, int(p.ly)-objectY0)
+
+	var palette int
+	if p.IsCGB {
+		palette = CGB_OBP0 + int(objectAttributes&0x07)
+	} else {
+		palette = DMG_OBP0 + int(objectAttributes&(1<<4)>>4)
+	}
+	isOAMOrder := p.IsCGB && p.opri&0x01 == 0
+
+	for f.obj.size < 8 {
+		f.obj.push(fifoPixel{})
+	}
+	skip := f.x + 8 - objectX // Pixels left of the screen
+	for b := max(skip, 0); b < 8; b++ {
+		lo := tileData[0] >> (7 - b) & 1
+		hi := tileData[1] >> (7 - b) & 1
+		colorIndex := hi<<1 | lo
+		if colorIndex == 0 {
+			continue
+		}
+		// The first object fetched keeps the pixel (= the smaller X on DMG),
+		// but the smaller OAM index wins in the CGB priority mode.
+		target := f.obj.at(b - skip)
+		if target.colorIndex != 0 && !(isOAMOrder && byte(oamIndex) < target.oamIndex) {
+			continue
+		}
+		*target = fifoPixel{
+			colorIndex: colorIndex,
+			palette:    palette,
+			isPriority: objectAttributes&(1<<7) != 0,
+			oamIndex:   byte(oamIndex),
+		}
+	}
+	f.isObjectFetched[f.objectFetch] = true
+	f.objectFetch = -1
+}
+
+// The mixPixels selects the background or the object pixel in the same way as objectsTransfer().
+func (p *PPU) mixPixels(bg, obj fifoPixel) color.RGBA {
+	isBGAndWindowEnableBitSet := p.lcdc&(1<<0) != 0
+	if !isBGAndWindowEnableBitSet && !p.IsCGB {
+		bg = fifoPixel{palette: DMG_BGP}
+	}
+	px := bg
+	isOBJEnableBitSet := p.lcdc&(1<<1) != 0
+	if obj.colorIndex != 0 && isOBJEnableBitSet {
+		isBGOver := false
+		if isBGAndWindowEnableBitSet && bg.colorIndex != 0 {
+			isBGOver = obj.isPriority || (p.IsCGB && bg.isPriority)
+		}
+		if !isBGOver {
+			px = obj
+		}
+	}
+	return p.getRGBA(px.colorIndex, px.palette)
+}
+
+func (q *pixelFIFO) saveState(e *savestate.Encoder) {
+	for _, px := range q.pixels {
+		e.Write(px.colorIndex)
+		e.WriteInt(px.palette)
+		e.Write(px.isPriority)
+		e.Write(px.oamIndex)
+	}
+	e.WriteInt(q.head)
+	e.WriteInt(q.size)
+}
+
+func (q *pixelFIFO) loadState(d *savestate.Decoder) {
+	for i := range q.pixels {
+		px := &q.pixels[i]
+		d.Read(&px.colorIndex)
+		d.ReadInt(&px.palette)
+		d.Read(&px.isPriority)
+		d.Read(&px.oamIndex)
+		d.Check(px.colorIndex <= 3 && px.palette >= 0 && px.palette < CGB_BGP0+8, "FIFO pixel")
+	}
+	d.ReadInt(&q.head)
+	d.ReadInt(&q.size)
+	d.Check(q.head >= 0 && q.head < len(q.pixels), "FIFO head")
+	d.Check(q.size >= 0 && q.size <= len(q.pixels), "FIFO size")
+}
+
+func (f *fifoRenderer) saveState(e *savestate.Encoder) {
+	f.bg.saveState(e)
+	f.obj.saveState(e)
+	e.WriteInt(f.fetchStep)
+	e.WriteInt(f.fetchX)
+	e.Write([4]byte{f.tileIndex, f.tileAttr, f.tileData[0], f.tileData[1]})
+	e.WriteInt(f.objectFetch)
+	e.WriteInt(f.objectFetchStep)
+	e.Write(f.isObjectFetched)
+	e.WriteInt(f.x)
+	e.WriteInt(f.discard)
+	e.WriteInt(f.delay)
+	e.Write([3]bool{f.isWindow, f.isWindowDrawn, f.isWYTriggered})
+}
+
+func (f *fifoRenderer) loadState(d *savestate.Decoder) {
+	f.bg.loadState(d)
+	f.obj.loadState(d)
+	d.ReadInt(&f.fetchStep)
+	d.ReadInt(&f.fetchX)
+	var tile [4]byte
+	d.Read(&tile)
+	f.tileIndex, f.tileAttr, f.tileData[0], f.tileData[1] = tile[0], tile[1], tile[2], tile[3]
+	d.ReadInt(&f.objectFetch)
+	d.ReadInt(&f.objectFetchStep)
+	d.Read(&f.isObjectFetched)
+	d.ReadInt(&f.x)
+	d.Check(f.x >= 0 && f.x <= 160, "FIFO x")
+	d.ReadInt(&f.discard)
+	d.ReadInt(&f.delay)
+	var flags [3]bool
+	d.Read(&flags)
+	f.isWindow, f.isWindowDrawn, f.isWYTriggered = flags[0], flags[1], flags[2]
+}
@@ -0,0 +1,143 @@
+package ppu
+
+import (
+	"image/color"
+	"testing"
+)
+
+// The setObjects enables the objects and puts them on line 5 at the X positions.
+func setObjects(p *PPU, xs ...byte) {
+	p.SetLCDC(p.GetLCDC() | 1<<1)
+	for i, x := range xs {
+		p.WriteOAM(uint16(i*4), 16+5)
+		p.WriteOAM(uint16(i*4+1), x)
+	}
+}
+
+// The measureMode3 returns the number of dots in mode 3 on line 5.
+func measureMode3(p *PPU) int {
+	p.Step(456*5 + 80) // Mode3 starts at dot 80.
+	n := 0
+	for p.GetSTAT()&0x03 == 3 {
+		p.Step(1)
+		n++
+	}
+	return n
+}
+
+// Both renderers take the same length of mode 3
+// (RendererFIFO by running the fetcher, RendererScanline by getMode3Length).
+func TestMode3Length(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(p *PPU)
+		want  int
+	}{
+		{"minimum", func(p *PPU) {}, 172},
+		{"SCX = 3", func(p *PPU) { p.SetSCX(3) }, 172 + 3},
+		{"SCX = 7", func(p *PPU) { p.SetSCX(7) }, 172 + 7},
+		{"SCX = 8", func(p *PPU) { p.SetSCX(8) }, 172},
+		{"window", func(p *PPU) { p.SetLCDC(p.GetLCDC() | 1<<5); p.SetWX(7 + 40) }, 172 + 6},
+		{"window off screen", func(p *PPU) { p.SetLCDC(p.GetLCDC() | 1<<5); p.SetWX(167) }, 172},
+		{"window below WY", func(p *PPU) { p.SetLCDC(0x11); p.SetWY(6); p.SetLCDC(0xB1) }, 172},
+		{"object at X = 0", func(p *PPU) { setObjects(p, 0) }, 172 + 5 + 6},
+		{"object at X = 8", func(p *PPU) { setObjects(p, 8) }, 172 + 5 + 6},
+		{"object at the start of a tile", func(p *PPU) { setObjects(p, 8+40) }, 172 + 5 + 6},
+		{"object in the middle of a tile", func(p *PPU) { setObjects(p, 8+43) }, 172 + 2 + 6},
+		{"object at X = 8 with SCX = 3", func(p *PPU) { p.SetSCX(3); setObjects(p, 8) }, 172 + 3 + 5 + 6},
+		{"object at X = 9 with SCX = 3", func(p *PPU) { p.SetSCX(3); setObjects(p, 9) }, 172 + 3 + 1 + 6},
+		{"objects in the same tile", func(p *PPU) { setObjects(p, 8+40, 8+42) }, 172 + 5 + 6 + 6},
+		{"objects in two tiles", func(p *PPU) { setObjects(p, 8+40, 8+80) }, 172 + 2*(5+6)},
+		{"10 objects", func(p *PPU) { setObjects(p, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48) }, 172 + 5 + 10*6},
+		{"11th object", func(p *PPU) { setObjects(p, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48) }, 172 + 5 + 10*6},
+		{"object off screen", func(p *PPU) { setObjects(p, 168) }, 172},
+		{"objects disabled", func(p *PPU) { setObjects(p, 8); p.SetLCDC(p.GetLCDC() &^ (1 << 1)) }, 172},
+	}
+	for _, r := range []Renderer{RendererScanline, RendererFIFO} {
+		for _, tt := range tests {
+			p := NewPPU()
+			p.Renderer = r
+			p.SetPostBootState()
+			tt.setup(p)
+			if got := measureMode3(p); got != tt.want {
+				t.Errorf("renderer %d, %s: mode 3 takes %d dots, want %d", r, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+// The fetcher waits fifoStartDelay dots at the start of mode 3, then outputs a pixel per dot.
+func TestFIFOStartDelay(t *testing.T) {
+	p := NewPPU()
+	p.Renderer = RendererFIFO
+	p.SetPostBootState()
+	p.Step(456*5 + 80)
+	p.Step(fifoStartDelay + 5) // The first pixel is output with the 6th dot of the first fetch.
+	if p.fifo.x != 0 {
+		t.Fatalf("x = %d before the first tile is fetched, want 0", p.fifo.x)
+	}
+	p.Step(10)
+	if p.fifo.x != 10 {
+		t.Errorf("x = %d after 10 more dots, want 10", p.fifo.x)
+	}
+}
+
+// The renderLine draws line 5 with RendererFIFO, calling write at dot 80+at.
+// It returns the pixels and the number of pixels output before the write.
+func renderLine(setup func(p *PPU), at int, write func(p *PPU)) ([]color.RGBA, int) {
+	p := NewPPU()
+	p.Renderer = RendererFIFO
+	p.SetPostBootState()
+	setup(p)
+	p.Step(456*5 + 80 + at)
+	x := p.fifo.x
+	if write != nil {
+		write(p)
+	}
+	p.Step(456 - 80 - at)
+	line := make([]color.RGBA, 160)
+	copy(line, p.fifoPixels[5*160:6*160])
+	return line, x
+}
+
+// A write during mode 3 changes only the pixels output after the write.
+func TestFIFOMidScanlineWrite(t *testing.T) {
+	// Tiles 0 and 1 have the color 1 and 2, and alternate in the map.
+	setup := func(p *PPU) {
+		p.SetBGP(0xE4)
+		for row := 0; row < 8; row++ {
+			p.WriteVRAM(0x8000+uint16(row*2), 0xFF)
+			p.WriteVRAM(0x8010+uint16(row*2+1), 0xFF)
+		}
+		for col := 0; col < 32; col++ {
+			p.WriteVRAM(0x9800+uint16(col), byte(col%2))
+		}
+	}
+	tests := []struct {
+		name  string
+		write func(p *PPU)
+	}{
+		{"BGP", func(p *PPU) { p.SetBGP(0x1B) }},
+		{"SCX", func(p *PPU) { p.SetSCX(8) }},
+	}
+	for _, tt := range tests {
+		want, _ := renderLine(setup, 90, nil)
+		got, x := renderLine(setup, 90, tt.write)
+		if x <= 0 || x >= 160 {
+			t.Fatalf("%s: written at x = %d, not in the middle of the line", tt.name, x)
+		}
+		for i := 0; i < x; i++ {
+			if got[i] != want[i] {
+				t.Errorf("%s: pixel %d before the write (x = %d) changed", tt.name, i, x)
+				break
+			}
+		}
+		isChanged := false
+		for i := x; i < 160; i++ {
+			isChanged = isChanged || got[i] != want[i]
+		}
+		if !isChanged {
+			t.Errorf("%s: no pixel after the write (x = %d) changed", tt.name, x)
+		}
+	}
+}
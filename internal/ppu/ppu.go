@@ -19,12 +19,33 @@ const (
 	Window     = 1
 )
 
+// The Renderer selects how the PPU draws the scanlines.
+type Renderer int
+
+const (
+	// The RendererScanline draws a whole scanline at the start of the line (fast).
+	RendererScanline Renderer = iota
+	// The RendererFIFO draws a pixel per dot with the pixel FIFOs,
+	// so writes to the registers during mode 3 take effect mid-scanline.
+	RendererFIFO
+)
+
+// Names for the settings (e.g. [video] renderer in config.toml)
+var RendererNames = map[string]Renderer{
+	"scanline": RendererScanline,
+	"fifo":     RendererFIFO,
+}
+
 var xFlipLUT [256]byte
 var expand5bitLUT [32]byte
 
 type PPU struct {
 	pixelClues [160 * 144]PixelClues
 	screen     *image.RGBA
+	Renderer   Renderer
+
+	fifo       fifoRenderer          // RendererFIFO only
+	fifoPixels [160 * 144]color.RGBA // Drawn by RendererFIFO, copied to the screen in VBlank
 
 	vram [2][0x2000]byte
 	oam  [160]byte
@@ -53,10 +74,19 @@ type PPU struct {
 	isPrevLCDC byte
 
 	drawingObjectList []int
+	scannedObjectList []int // Objects on the current scanline in OAM order
 
 	// PPU Internal Counters
-	wly  int
-	dots int
+	wly            int
+	dots           int
+	mode3End       int  // Dot at which mode 3 ends (RendererScanline only)
+	isLastLine     bool // LY = 153 reads 0 from its 4th dot.
+	isWindowOnLine bool // The window is drawn on the current line (RendererScanline only)
+	isBlankFrame   bool // The first frame after turning the LCD on is not shown.
+
+	// STAT interrupt line (see updateSTATLine)
+	statLine         bool
+	isOAMIntAtVBlank bool // The Mode2 source is also active when LY = 144 starts.
 
 	// Interrupt
 	HasLCDInterruptRequested    bool
@@ -72,6 +102,9 @@ type PPU struct {
 	VDMASrc uint16 // CGB mode only
 	VDMALen int    // CGB mode only
 	VDMADst uint16 // CGB mode only
+
+	IsHBlankDMAActive bool // CGB mode only
+	HasHDMARequested  bool // Set at the start of each HBlank while the HBlank DMA is active.
 }
 
 type PixelClues struct {
@@ -80,11 +113,13 @@ type PixelClues struct {
 	isCGBBGMapPriorityBitSet bool //
 }
 
+// The NewPPU returns the PPU in the power-on state (LCD off, palettes cleared).
+// Without a boot ROM, SetPostBootState must be called before running.
 func NewPPU() *PPU {
 	calcXFlipLUT()
 	calcExpand5bitLUT()
 	p := &PPU{
-		stat:   0x85,
+		stat:   0x80,
 		ly:     0x00,
 		lyc:    0x00,
 		wy:     0x00,
@@ -98,12 +133,18 @@ func NewPPU() *PPU {
 			{64, 128, 64, 255},
 			{0, 24, 0, 255},
 		},
+		fifo: fifoRenderer{objectFetch: -1},
 	}
+	return p
+}
+
+// The SetPostBootState turns on the LCD and sets the palettes as the boot ROM leaves them.
+func (p *PPU) SetPostBootState() {
+	p.stat = 0x85
 	p.SetLCDC(0x91)
 	p.SetBGP(0xFC)
 	p.SetOBP0(0xFF)
 	p.SetOBP1(0xFF)
-	return p
 }
 
 func calcXFlipLUT() {
@@ -136,57 +177,155 @@ func calcExpand5bitLUT() {
 	}
 }
 
+// The Step advances the PPU dot by dot (1 dot == 1 CPU cycle at normal speed).
 func (p *PPU) Step(cpuCycles int) {
+	for range cpuCycles {
+		p.stepDot()
+	}
+}
+
+// Each line takes 456 dots.
+// LY = 0 ~ 143: Mode2 (80 dots), Mode3 (172 ~ 289 dots), Mode0 (the rest)
+// LY = 144 ~ 153: Mode1 (VBlank)
+func (p *PPU) stepDot() {
+	// While the LCD is off, the PPU is stopped at LY = 0, dot 0, Mode0.
+	if p.lcdc&(1<<7) == 0 {
+		return
+	}
 
-	// 1 Line == 456 CPU cycles
-	p.dots += cpuCycles
+	p.dots++
 	if p.dots >= 456 {
 		p.dots -= 456
-		p.ly++
-		if p.ly == 154 {
-			p.ly = 0
-		}
-
-		// In case of PPU disabled
-		if p.lcdc&(1<<7) == 0 {
-			p.ly = 0
-			p.wly = 0
-			return
-		}
-
-		// Check LYC == LY Interrupts.
-		p.stat = p.stat &^ (1 << 2) // LYC == LY bit clear
-		if p.lyc == p.ly {
-			p.stat |= 1 << 2
-			if p.stat&(1<<6) != 0 { // Is LYC interrupt selected?
-				p.HasLCDInterruptRequested = true
-			}
-		}
+		p.nextLine()
 
 		switch {
-		// During the period LY = 0 ~ 143, repeat Mode2, Mode3, Mode0, Mode2... every LY.
 		case p.ly <= 143:
-			// The cartridge program may set the drawing options to LCDC etc. later than dot = 0,
-			// so the start of the drawing process is delayed a little.
-			/* if p.hasTransferRq && p.dots >= 280 { */
 			p.setPPUMode(2)
+			p.checkWY()
+		// VBlank IRQ occurs only once at the moment LY = 144 is reached.
+		case p.ly == 144:
+			p.startVBlank()
+		}
+		p.updateSTATLine()
+		p.isOAMIntAtVBlank = false
+		return
+	}
+
+	switch p.stat & 0x03 {
+	case 1:
+		// LY reads 0 from the 4th dot of the last line (LY = 153).
+		if p.ly == 153 && p.dots == 4 {
+			p.ly = 0
+			p.isLastLine = true
+			p.compareLYC()
+			p.updateSTATLine()
+		}
+	// The first line after the LCD is turned on has Mode0 instead of Mode2.
+	case 0, 2:
+		if p.dots == 80 {
 			p.oamSearch()
 			p.setPPUMode(3)
-			p.pixelTransfer()
+			if p.Renderer == RendererFIFO {
+				p.startPixelTransfer()
+			} else {
+				p.pixelTransfer()
+				p.mode3End = 80 + p.getMode3Length()
+			}
+			p.updateSTATLine()
+		}
+	case 3:
+		isDone := false
+		if p.Renderer == RendererFIFO {
+			isDone = p.stepPixelTransfer()
+		} else {
+			isDone = p.dots >= p.mode3End
+		}
+		if isDone {
+			if p.Renderer == RendererFIFO && p.fifo.isWindowDrawn {
+				p.wly++
+			}
 			p.setPPUMode(0)
-		// VBlank during LY = 144 ~ 153 (VBlank IRQ occurs only once at the moment LY = 144 is reached).
-		case p.ly == 144:
-			p.setPPUMode(1)
-			p.HasVBlankInterruptRequested = true
-			p.wly = 0
-			p.drawGameBoyScreen()
+			p.updateSTATLine()
+			if p.IsHBlankDMAActive {
+				p.HasHDMARequested = true
+			}
+		}
+	}
+}
+
+// The nextLine increments LY and checks LYC == LY.
+func (p *PPU) nextLine() {
+	if p.isLastLine { // LY is already 0.
+		p.isLastLine = false
+	} else {
+		p.ly++
+	}
+	if p.ly == 154 {
+		p.ly = 0
+	}
+	p.compareLYC()
+}
+
+// The window is shown from the line where LY matches WY until the end of the frame (RendererFIFO).
+func (p *PPU) checkWY() {
+	if p.ly == p.wy {
+		p.fifo.isWYTriggered = true
+	}
+}
+
+func (p *PPU) compareLYC() {
+	p.stat = p.stat &^ (1 << 2) // LYC == LY bit clear
+	if p.lyc == p.ly {
+		p.stat |= 1 << 2
+	}
+}
+
+func (p *PPU) startVBlank() {
+	p.setPPUMode(1)
+	p.HasVBlankInterruptRequested = true
+	p.isOAMIntAtVBlank = true
+	p.wly = 0
+	p.fifo.isWYTriggered = false
+	p.drawGameBoyScreen()
+	p.isBlankFrame = false
+}
+
+// The getMode3Length estimates the length of mode 3 for RendererScanline
+// in the same way as RendererFIFO: 172 dots + SCX fine scroll + window + objects.
+func (p *PPU) getMode3Length() int {
+	length := 172 + int(p.scx&0x07)
+	if p.isWindowOnLine {
+		length += 6
+	}
+	if p.lcdc&(1<<1) == 0 {
+		return length
+	}
+	// An object waits for the background fetcher to finish the tile (up to 5 dots),
+	// then takes 6 dots. (The wait is only once per tile.)
+	var isTileWaited [21]bool
+	for _, oamIndex := range p.scannedObjectList {
+		objectX := int(p.oam[oamIndex<<2+1])
+		if objectX >= 168 {
+			continue
+		}
+		// Objects at X <= 8 are fetched before the SCX fine scroll pixels are dropped.
+		x := 0
+		if objectX > 8 {
+			x = objectX - 8 + int(p.scx&0x07)
+		}
+		tile := x / 8
+		if !isTileWaited[tile] {
+			isTileWaited[tile] = true
+			length += 5 - min(5, x%8)
 		}
-		p.checkSTATInt()
+		length += 6
 	}
+	return length
 }
 
 // Update the frame buffer by one line
 func (p *PPU) pixelTransfer() {
+	p.isWindowOnLine = false
 
 	isBGAndWindowEnableBitSet := p.lcdc&(1<<0) != 0
 	if isBGAndWindowEnableBitSet || p.IsCGB {
@@ -204,6 +343,7 @@ func (p *PPU) pixelTransfer() {
 				if isWindowDrawn {
 					p.wly++
 				}
+				p.isWindowOnLine = isWindowDrawn
 			}
 		}
 		p.isPrevLCDC = p.lcdc
@@ -218,13 +358,19 @@ func (p *PPU) setPPUMode(nextMode int) {
 	p.stat = p.stat&0x7C | (byte(nextMode) & 0x03)
 }
 
-func (p *PPU) checkSTATInt() {
+// The STAT interrupt line is the OR of the selected sources,
+// and the interrupt is requested only when the line goes from low to high ("STAT blocking").
+func (p *PPU) updateSTATLine() {
 	mode := p.stat & 0x03
-
-	isModeXIntSelectBitSet := p.stat&(1<<(mode+3)) != 0
-	if isModeXIntSelectBitSet {
+	isLCDOn := p.lcdc&(1<<7) != 0
+	line := isLCDOn && ((p.stat&(1<<6) != 0 && p.stat&(1<<2) != 0) ||
+		(p.stat&(1<<3) != 0 && mode == 0) ||
+		(p.stat&(1<<4) != 0 && mode == 1) ||
+		(p.stat&(1<<5) != 0 && (mode == 2 || p.isOAMIntAtVBlank)))
+	if line && !p.statLine {
 		p.HasLCDInterruptRequested = true
 	}
+	p.statLine = line
 }
 
 // The oamSearch lists up to 10 objects to be displayed on the current scanline,
@@ -235,17 +381,18 @@ func (p *PPU) oamSearch() {
 	if p.lcdc&(1<<2) != 0 {
 		objectHeight = 16
 	}
-	unsortedList := []int{}
+	p.scannedObjectList = p.scannedObjectList[:0]
 	for i := 0; i < 40; i++ {
 		objectY0 := int(p.oam[i<<2+0]) - 16
 		areOverlapping := objectY0 <= ly && ly < objectY0+objectHeight
 		if areOverlapping {
-			unsortedList = append(unsortedList, i)
-			if len(unsortedList) == 10 {
+			p.scannedObjectList = append(p.scannedObjectList, i)
+			if len(p.scannedObjectList) == 10 {
 				break
 			}
 		}
 	}
+	unsortedList := p.scannedObjectList
 
 	// Sort the list "X-position descendig" or "OAM index descending".
 	p.drawingObjectList = p.drawingObjectList[:0]
@@ -492,8 +639,8 @@ func (p *PPU) drawGameBoyScreen() {
 	for y := 0; y < 144; y++ {
 		for x := 0; x < 160; x++ {
 			target := y*160 + x
-			//
-			if p.lcdc&(1<<7) == 0 {
+			// While the LCD is off (and in the first frame after turning it on), the screen is blank.
+			if p.lcdc&(1<<7) == 0 || p.isBlankFrame {
 				if p.IsCGB {
 					p.screen.SetRGBA(x, y, color.RGBA{expand5bitLUT[31], expand5bitLUT[31], expand5bitLUT[31], 255})
 				} else {
@@ -501,48 +648,53 @@ func (p *PPU) drawGameBoyScreen() {
 				}
 				continue
 			}
-			rgba := color.RGBA{255, 255, 255, 255}
-			colorIndex := p.pixelClues[target].colorIndex // colorIndex = 0 ~ 3
-			palette := p.pixelClues[target].palette
-
-			// In this package, the palette index constants are assigned in the order DMG, CGB.
-			if p.IsCGB { // If palette value is greater than DMG_OBP1, it is a CGB palette.
-				var paletteOffset int
-				var ram *[64]byte
-				if palette >= CGB_BGP0 && palette < CGB_BGP0+8 {
-					paletteOffset = palette - CGB_BGP0
-					ram = &p.bgpRAM
-				} else if palette >= CGB_OBP0 && palette < CGB_OBP0+8 {
-					paletteOffset = palette - CGB_OBP0
-					ram = &p.obpRAM
-				}
+			if p.Renderer == RendererFIFO {
+				p.screen.SetRGBA(x, y, p.fifoPixels[target])
+				continue
+			}
+			p.screen.SetRGBA(x, y, p.getRGBA(p.pixelClues[target].colorIndex, p.pixelClues[target].palette))
+		}
+	}
+}
 
-				// One CGB palette size is 8 Bytes. One CGB color size is 2 Bytes.
-				baseAddr := paletteOffset*8 + int(colorIndex)*2
-				lo := uint16(ram[baseAddr])
-				hi := uint16(ram[baseAddr+1])
-				r := byte(lo & 0b00011111)
-				g := byte(hi&0b00000011<<3 | lo&0b11100000>>5)
-				b := byte(hi & 0b01111100 >> 2)
-				// CGB pixels are converted from RGB555 format.
-				rgba = color.RGBA{expand5bitLUT[r], expand5bitLUT[g], expand5bitLUT[b], 255}
-			} else {
-				var paletteRegister byte
-				switch palette {
-				case DMG_BGP:
-					paletteRegister = p.bgp
-				case DMG_OBP0:
-					paletteRegister = p.obp0
-				case DMG_OBP1:
-					paletteRegister = p.obp1
-				}
-				finalGrayShadeIndex := paletteRegister >> (colorIndex * 2) & 0x03
-				rgba = p.dmgRGBAColorList[finalGrayShadeIndex]
+// The getRGBA converts the colorIndex (0 ~ 3) to RGBA with the current palette.
+func (p *PPU) getRGBA(colorIndex byte, palette int) color.RGBA {
+	rgba := color.RGBA{255, 255, 255, 255}
+	// In this package, the palette index constants are assigned in the order DMG, CGB.
+	if p.IsCGB { // If palette value is greater than DMG_OBP1, it is a CGB palette.
+		var paletteOffset int
+		var ram *[64]byte
+		if palette >= CGB_BGP0 && palette < CGB_BGP0+8 {
+			paletteOffset = palette - CGB_BGP0
+			ram = &p.bgpRAM
+		} else if palette >= CGB_OBP0 && palette < CGB_OBP0+8 {
+			paletteOffset = palette - CGB_OBP0
+			ram = &p.obpRAM
+		}
 
-			}
-			p.screen.SetRGBA(x, y, rgba)
+		// One CGB palette size is 8 Bytes. One CGB color size is 2 Bytes.
+		baseAddr := paletteOffset*8 + int(colorIndex)*2
+		lo := uint16(ram[baseAddr])
+		hi := uint16(ram[baseAddr+1])
+		r := byte(lo & 0b00011111)
+		g := byte(hi&0b00000011<<3 | lo&0b11100000>>5)
+		b := byte(hi & 0b01111100 >> 2)
+		// CGB pixels are converted from RGB555 format.
+		rgba = color.RGBA{expand5bitLUT[r], expand5bitLUT[g], expand5bitLUT[b], 255}
+	} else {
+		var paletteRegister byte
+		switch palette {
+		case DMG_BGP:
+			paletteRegister = p.bgp
+		case DMG_OBP0:
+			paletteRegister = p.obp0
+		case DMG_OBP1:
+			paletteRegister = p.obp1
 		}
+		finalGrayShadeIndex := paletteRegister >> (colorIndex * 2) & 0x03
+		rgba = p.dmgRGBAColorList[finalGrayShadeIndex]
 	}
+	return rgba
 }
 
 // Get Viewport pixels converted from colorIndex to RGBA
@@ -550,6 +702,45 @@ func (p *PPU) GetGameScreen() *image.RGBA {
 	return p.screen
 }
 
+// The ToReferenceColor converts a color of the screen to the one used by the reference images
+// of test ROMs (dmg-acid2, cgb-acid2), as GOmeBoy uses its own colors.
+// DMG shades become $FF/$AA/$55/$00 grays, and CGB channels are expanded as (c << 3) | (c >> 2).
+func (p *PPU) ToReferenceColor(c color.RGBA) color.RGBA {
+	if !p.IsCGB {
+		for i, dc := range p.dmgRGBAColorList {
+			if c == dc {
+				v := byte(0xFF - 0x55*i)
+				return color.RGBA{v, v, v, 255}
+			}
+		}
+		return c
+	}
+	return color.RGBA{toReference5bit(c.R), toReference5bit(c.G), toReference5bit(c.B), 255}
+}
+
+// The toReference5bit finds the 5-bit value of an expanded channel.
+func toReference5bit(v byte) byte {
+	for i, e := range expand5bitLUT {
+		if e == v {
+			return byte(i<<3 | i>>2)
+		}
+	}
+	return v
+}
+
+// The IsVRAMLocked reports whether the PPU is reading VRAM (Mode3),
+// so the CPU cannot access it.
+func (p *PPU) IsVRAMLocked() bool {
+	return p.lcdc&(1<<7) != 0 && p.stat&0x03 == 3
+}
+
+// The IsOAMLocked reports whether the PPU is reading OAM (Mode2 and Mode3),
+// so the CPU cannot access it.
+func (p *PPU) IsOAMLocked() bool {
+	mode := p.stat & 0x03
+	return p.lcdc&(1<<7) != 0 && (mode == 2 || mode == 3)
+}
+
 func (p *PPU) ReadVRAM(addr uint16) byte {
 	offset := addr & 0x1FFF // To prevent out of range errors
 	return p.vram[p.vbk][offset]
@@ -589,7 +780,39 @@ func (p *PPU) GetLCDC() byte {
 }
 
 func (p *PPU) SetLCDC(val byte) {
+	isOn := p.lcdc&(1<<7) != 0
 	p.lcdc = val
+	switch {
+	case isOn && val&(1<<7) == 0:
+		p.turnLCDOff()
+	case !isOn && val&(1<<7) != 0:
+		p.turnLCDOn()
+	}
+}
+
+// Turning the LCD off resets LY and the mode to 0, and frees VRAM and OAM for the CPU.
+// The blank screen is drawn at once, so the frontend never sees a half-drawn frame.
+func (p *PPU) turnLCDOff() {
+	p.ly = 0
+	p.wly = 0
+	p.dots = 0
+	p.isLastLine = false
+	p.setPPUMode(0)
+	p.updateSTATLine()
+	p.drawGameBoyScreen()
+}
+
+// The PPU starts from LY = 0 again, but the first frame is not shown.
+func (p *PPU) turnLCDOn() {
+	p.ly = 0
+	p.wly = 0
+	p.dots = 0
+	p.isBlankFrame = true
+	p.fifo.isWYTriggered = false
+	p.compareLYC()
+	p.checkWY()
+	p.setPPUMode(0)
+	p.updateSTATLine()
 }
 
 func (p *PPU) GetSTAT() byte {
@@ -597,10 +820,16 @@ func (p *PPU) GetSTAT() byte {
 }
 
 func (p *PPU) SetSTAT(val byte) {
-	p.stat = (val & 0x7C) | (p.stat & 0x03)
+	// On DMG, all the sources are selected for a moment while writing,
+	// so the write itself requests the interrupt in HBlank, VBlank or LY == LYC.
+	if !p.IsCGB {
+		p.stat |= 0x58
+		p.updateSTATLine()
+	}
+	p.stat = 0x80 | (val & 0x78) | (p.stat & 0x07)
 
 	// If any Mode int select is changed, check for interrupts
-	p.checkSTATInt()
+	p.updateSTATLine()
 }
 
 func (p *PPU) GetLY() byte {
@@ -611,8 +840,14 @@ func (p *PPU) GetLYC() byte {
 	return p.lyc
 }
 
+// Writing LYC compares it with LY at once, so a match requests the STAT interrupt
+// without waiting for the next line. (While the LCD is off, the flag keeps its value.)
 func (p *PPU) SetLYC(val byte) {
 	p.lyc = val
+	if p.lcdc&(1<<7) != 0 {
+		p.compareLYC()
+		p.updateSTATLine()
+	}
 }
 
 func (p *PPU) GetOBP0() byte {
@@ -751,21 +986,34 @@ func (p *PPU) SetHDMA4(val byte) {
 	p.VDMADst = p.VDMADst&0x1F00 | uint16(val)&0x00F0
 }
 
-// incomplete implementation
+// Bit 7 = 0 while the HBlank DMA is active, and the lower bits are the remaining blocks - 1.
+// After the transfer is done, it reads 0xFF. (After it is cancelled, bit 7 = 1 with the remaining blocks.)
 func (p *PPU) GetHDMA5() byte {
 	//fmt.Println("GetHDMA5")
 	if p.VDMALen == 0 {
 		return 0xFF
-	} else {
-		return byte(p.VDMALen/0x10 - 1)
 	}
+	remaining := byte(p.VDMALen/0x10-1) & 0x7F
+	if p.IsHBlankDMAActive {
+		return remaining
+	}
+	return 0x80 | remaining
 }
 
 // VRAM DMA length/mode/start
+// Writing bit 7 = 0 while the HBlank DMA is active cancels it.
+// The HBlank DMA started in HBlank or with the LCD off copies the first block at once.
 func (p *PPU) SetHDMA5(val byte) {
 	//fmt.Println("SetHDMA5")
+	if p.IsHBlankDMAActive && val&0x80 == 0 {
+		p.IsHBlankDMAActive = false
+		return
+	}
 	p.VDMALen = (int(val)&0x7F + 1) * 0x10 // Therefore, transfer length == $10 ~ $800 Bytes
-	//mode := p.hdma5 & 0x80 // 0 == General-purpose DMA        1 == HBlank DMA
+	p.IsHBlankDMAActive = val&0x80 != 0    // 0 == General-purpose DMA        1 == HBlank DMA
+	if p.IsHBlankDMAActive && (p.lcdc&(1<<7) == 0 || p.stat&0x03 == 0) {
+		p.HasHDMARequested = true
+	}
 	// Transfer is done via Bus
 }
 
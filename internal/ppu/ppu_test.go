@@ -0,0 +1,258 @@
+package ppu
+
+import (
+	"bytes"
+	"errors"
+	"gomeboy/internal/savestate"
+	"image/color"
+	"testing"
+)
+
+// The newRunningPPU returns a PPU with the LCD on, at dot 200 of line ly.
+func newRunningPPU(ly int) *PPU {
+	p := NewPPU()
+	p.SetPostBootState()
+	p.Step(456*ly + 200)
+	p.HasLCDInterruptRequested = false
+	return p
+}
+
+func TestSetLYC(t *testing.T) {
+	p := newRunningPPU(5)
+	p.SetSTAT(0x40) // LYC == LY source
+	p.HasLCDInterruptRequested = false
+
+	p.SetLYC(5)
+	if p.GetSTAT()&(1<<2) == 0 {
+		t.Error("LYC == LY flag is not set by writing LYC = LY")
+	}
+	if !p.HasLCDInterruptRequested {
+		t.Error("no STAT interrupt by writing LYC = LY")
+	}
+
+	p.HasLCDInterruptRequested = false
+	p.SetLYC(6)
+	if p.GetSTAT()&(1<<2) != 0 {
+		t.Error("LYC == LY flag is still set after writing LYC != LY")
+	}
+	if p.HasLCDInterruptRequested {
+		t.Error("STAT interrupt by writing LYC != LY")
+	}
+
+	// The line goes low, so a match requests the interrupt again.
+	p.SetLYC(5)
+	if !p.HasLCDInterruptRequested {
+		t.Error("no STAT interrupt by the second match")
+	}
+}
+
+func TestSetLYCWhileLCDOff(t *testing.T) {
+	p := newRunningPPU(5)
+	p.SetLYC(0)
+	p.SetLCDC(p.GetLCDC() &^ (1 << 7))
+	flag := p.GetSTAT() & (1 << 2)
+	p.SetLYC(7)
+	if p.GetSTAT()&(1<<2) != flag {
+		t.Error("LYC == LY flag changed while the LCD is off")
+	}
+}
+
+// Turning the LCD on compares LY = 0 with LYC at once.
+func TestLCDOnComparesLYC(t *testing.T) {
+	p := newRunningPPU(5)
+	p.SetLCDC(p.GetLCDC() &^ (1 << 7))
+	p.SetSTAT(0x40)
+	p.SetLYC(0)
+	p.HasLCDInterruptRequested = false
+
+	p.SetLCDC(p.GetLCDC() | 1<<7)
+	if p.GetSTAT()&(1<<2) == 0 {
+		t.Error("LYC == LY flag is not set after turning the LCD on")
+	}
+	if !p.HasLCDInterruptRequested {
+		t.Error("no STAT interrupt after turning the LCD on with LYC = 0")
+	}
+}
+
+func TestLoadStateRejectsOutOfRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *PPU)
+	}{
+		{"FIFO head", func(p *PPU) { p.fifo.bg.head = 16 }},
+		{"FIFO size", func(p *PPU) { p.fifo.obj.size = 17 }},
+		{"FIFO pixel", func(p *PPU) { p.fifo.bg.pixels[3].colorIndex = 4 }},
+		{"FIFO x", func(p *PPU) { p.fifo.x = 161 }},
+		{"FIFO object fetch", func(p *PPU) { p.fifo.objectFetch = len(p.scannedObjectList) }},
+		{"PPU dots", func(p *PPU) { p.dots = 456 }},
+		{"LY", func(p *PPU) { p.ly = 154 }},
+		{"VBK", func(p *PPU) { p.vbk = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newRunningPPU(5)
+			tt.modify(p)
+			var buf bytes.Buffer
+			e := savestate.NewEncoder(&buf)
+			p.SaveState(e)
+			if e.Err() != nil {
+				t.Fatal(e.Err())
+			}
+
+			d := savestate.NewDecoder(&buf)
+			NewPPU().LoadState(d)
+			var rerr *savestate.RangeError
+			if !errors.As(d.Err(), &rerr) || rerr.Name != tt.name {
+				t.Errorf("err = %v, want the RangeError of %s", d.Err(), tt.name)
+			}
+		})
+	}
+}
+
+func TestLoadStateInMode3(t *testing.T) {
+	for _, r := range []Renderer{RendererScanline, RendererFIFO} {
+		p := NewPPU()
+		p.Renderer = r
+		p.SetPostBootState()
+		p.Step(456*5 + 100) // Mode3
+		var buf bytes.Buffer
+		e := savestate.NewEncoder(&buf)
+		p.SaveState(e)
+		d := savestate.NewDecoder(&buf)
+		NewPPU().LoadState(d)
+		if d.Err() != nil {
+			t.Errorf("renderer %d: %v", r, d.Err())
+		}
+	}
+}
+
+// The isScreenFilled reports whether every pixel of the screen has the color.
+func isScreenFilled(p *PPU, c color.RGBA) bool {
+	screen := p.GetGameScreen()
+	for y := 0; y < 144; y++ {
+		for x := 0; x < 160; x++ {
+			if screen.RGBAAt(x, y) != c {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Turning the LCD off stops the PPU at LY = 0, dot 0, mode 0, and blanks the screen.
+func TestLCDOff(t *testing.T) {
+	p := NewPPU()
+	p.SetPostBootState()
+	p.SetLCDC(p.GetLCDC() | 1<<5) // Window at WY = 0
+	p.SetWX(7)
+	p.SetBGP(0xFF)
+	p.Step(456*100 + 200)
+	if p.wly == 0 {
+		t.Fatal("the window line counter is 0 before turning the LCD off")
+	}
+
+	p.SetLCDC(p.GetLCDC() &^ (1 << 7))
+	p.HasVBlankInterruptRequested = false
+	p.Step(456 * 154)
+	if p.GetLY() != 0 || p.wly != 0 || p.dots != 0 || p.GetSTAT()&0x03 != 0 {
+		t.Errorf("LY = %d, WLY = %d, dots = %d, mode = %d, want all 0",
+			p.GetLY(), p.wly, p.dots, p.GetSTAT()&0x03)
+	}
+	if p.HasVBlankInterruptRequested {
+		t.Error("VBlank interrupt while the LCD is off")
+	}
+	if p.IsVRAMLocked() || p.IsOAMLocked() {
+		t.Error("VRAM or OAM is locked while the LCD is off")
+	}
+	if !isScreenFilled(p, p.dmgRGBAColorList[0]) {
+		t.Error("the screen is not blank while the LCD is off")
+	}
+}
+
+// The first line after turning the LCD on has mode 0 instead of mode 2,
+// and the first frame is not shown.
+func TestLCDOnBlankFrame(t *testing.T) {
+	p := NewPPU()
+	p.SetPostBootState()
+	p.SetBGP(0xFF)
+	p.SetLCDC(p.GetLCDC() &^ (1 << 7))
+	p.SetLCDC(p.GetLCDC() | 1<<7)
+
+	p.Step(79)
+	if mode := p.GetSTAT() & 0x03; mode != 0 {
+		t.Errorf("mode = %d at dot 79 of the first line, want 0", mode)
+	}
+	p.Step(1)
+	if mode := p.GetSTAT() & 0x03; mode != 3 {
+		t.Errorf("mode = %d at dot 80 of the first line, want 3", mode)
+	}
+
+	p.Step(456*144 - 80) // The first VBlank
+	if !isScreenFilled(p, p.dmgRGBAColorList[0]) {
+		t.Error("the first frame after turning the LCD on is shown")
+	}
+	p.Step(456 * 154) // The second VBlank
+	if !isScreenFilled(p, p.dmgRGBAColorList[3]) {
+		t.Error("the second frame after turning the LCD on is not shown")
+	}
+}
+
+// While the LCD is off, STAT reads mode 0, but no source requests the STAT interrupt.
+func TestSTATWhileLCDOff(t *testing.T) {
+	for _, isCGB := range []bool{false, true} {
+		p := newRunningPPU(5)
+		p.IsCGB = isCGB
+		p.SetLCDC(p.GetLCDC() &^ (1 << 7))
+		p.HasLCDInterruptRequested = false
+
+		p.SetSTAT(0x48) // HBlank and LYC == LY sources
+		p.SetLYC(0)
+		p.Step(456)
+		if p.HasLCDInterruptRequested {
+			t.Errorf("CGB = %v: STAT interrupt while the LCD is off", isCGB)
+		}
+		if got := p.GetSTAT(); got&0x03 != 0 || got&0x78 != 0x48 {
+			t.Errorf("CGB = %v: STAT = %02X, want mode 0 with the sources 48", isCGB, got)
+		}
+	}
+}
+
+// On DMG, writing STAT selects all the sources for a moment,
+// so the write requests the STAT interrupt in HBlank, VBlank or LY == LYC.
+func TestSetSTATQuirk(t *testing.T) {
+	tests := []struct {
+		name  string
+		dots  int // From the start of the frame after the first one
+		lyc   byte
+		stat  byte // Sources selected before the write
+		isCGB bool
+		want  bool
+	}{
+		{name: "HBlank", dots: 456*5 + 300, lyc: 0xFF, want: true},
+		{name: "VBlank", dots: 456*145 + 100, lyc: 0xFF, want: true},
+		{name: "OAM scan", dots: 456*5 + 40, lyc: 0xFF, want: false},
+		{name: "mode 3", dots: 456*5 + 100, lyc: 0xFF, want: false},
+		{name: "mode 3 with LY == LYC", dots: 456*5 + 100, lyc: 5, want: true},
+		{name: "HBlank with the line already high", dots: 456*5 + 300, lyc: 0xFF, stat: 0x08, want: false},
+		{name: "CGB HBlank", dots: 456*5 + 300, lyc: 0xFF, isCGB: true, want: false},
+		{name: "CGB mode 3 with LY == LYC", dots: 456*5 + 100, lyc: 5, isCGB: true, want: false},
+	}
+	for _, tt := range tests {
+		p := NewPPU()
+		p.IsCGB = tt.isCGB
+		p.SetPostBootState()
+		p.Step(456 * 154)
+		p.SetLYC(tt.lyc)
+		p.SetSTAT(tt.stat)
+		p.Step(tt.dots)
+		p.HasLCDInterruptRequested = false
+
+		p.SetSTAT(0x00)
+		if p.HasLCDInterruptRequested != tt.want {
+			t.Errorf("%s: STAT interrupt = %v, want %v", tt.name, p.HasLCDInterruptRequested, tt.want)
+		}
+		if got := p.GetSTAT() & 0x78; got != 0 {
+			t.Errorf("%s: sources = %02X after writing 0, want 00", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package ppu
+
+import "gomeboy/internal/savestate"
+
+// The pixel buffer and the screen are not saved.
+// They are redrawn from VRAM/OAM within one frame after loading.
+func (p *PPU) SaveState(e *savestate.Encoder) {
+	e.Write(&p.vram)
+	e.Write(&p.oam)
+	e.Write([18]byte{
+		p.lcdc, p.dma, p.stat, p.ly, p.lyc, p.wy, p.wx, p.scy, p.scx,
+		p.obp0, p.obp1, p.bgp, p.bcps, p.bcpd, p.ocps, p.ocpd, p.opri, p.vbk,
+	})
+	e.Write(p.isPrevLCDC)
+	e.WriteInt(p.wly)
+	e.WriteInt(p.dots)
+	e.WriteInt(p.mode3End)
+	e.Write([5]bool{p.isLastLine, p.isWindowOnLine, p.statLine, p.isOAMIntAtVBlank, p.isBlankFrame})
+	e.Write(p.HasLCDInterruptRequested)
+	e.Write(p.HasVBlankInterruptRequested)
+	e.Write(&p.bgpRAM)
+	e.Write(&p.obpRAM)
+	e.Write(p.IsCGB)
+	e.Write(p.VDMASrc)
+	e.WriteInt(p.VDMALen)
+	e.Write(p.VDMADst)
+	e.Write(p.IsHBlankDMAActive)
+	e.Write(p.HasHDMARequested)
+
+	// RendererFIFO may be in the middle of mode 3.
+	p.fifo.saveState(e)
+	e.WriteInt(len(p.scannedObjectList))
+	for _, i := range p.scannedObjectList {
+		e.Write(byte(i))
+	}
+}
+
+func (p *PPU) LoadState(d *savestate.Decoder) {
+	d.Read(&p.vram)
+	d.Read(&p.oam)
+	var regs [18]byte
+	d.Read(&regs)
+	p.lcdc, p.dma, p.stat, p.ly, p.lyc, p.wy, p.wx, p.scy, p.scx = regs[0], regs[1], regs[2], regs[3], regs[4], regs[5], regs[6], regs[7], regs[8]
+	p.obp0, p.obp1, p.bgp, p.bcps, p.bcpd, p.ocps, p.ocpd, p.opri, p.vbk = regs[9], regs[10], regs[11], regs[12], regs[13], regs[14], regs[15], regs[16], regs[17]
+	d.Read(&p.isPrevLCDC)
+	d.Check(p.vbk <= 1, "VBK")
+	// Mode 0, 2 and 3 draw the line LY.
+	d.Check(p.ly <= 153 && (p.stat&0x03 == 1 || p.ly <= 143), "LY")
+	d.ReadInt(&p.wly)
+	d.Check(p.wly >= 0 && p.wly <= 0xFF, "WLY")
+	d.ReadInt(&p.dots)
+	d.Check(p.dots >= 0 && p.dots < 456, "PPU dots")
+	d.ReadInt(&p.mode3End)
+	var flags [5]bool
+	d.Read(&flags)
+	p.isLastLine, p.isWindowOnLine, p.statLine, p.isOAMIntAtVBlank, p.isBlankFrame = flags[0], flags[1], flags[2], flags[3], flags[4]
+	d.Read(&p.HasLCDInterruptRequested)
+	d.Read(&p.HasVBlankInterruptRequested)
+	d.Read(&p.bgpRAM)
+	d.Read(&p.obpRAM)
+	d.Read(&p.IsCGB)
+	d.Read(&p.VDMASrc)
+	d.ReadInt(&p.VDMALen)
+	d.Check(p.VDMALen >= 0 && p.VDMALen <= 0x800, "HDMA length")
+	d.Read(&p.VDMADst)
+	d.Read(&p.IsHBlankDMAActive)
+	d.Read(&p.HasHDMARequested)
+
+	p.fifo.loadState(d)
+	var n int
+	d.ReadInt(&n)
+	d.Check(n >= 0 && n <= 10, "scanned objects")
+	p.scannedObjectList = p.scannedObjectList[:0]
+	for range min(max(n, 0), 10) {
+		var i byte
+		d.Read(&i)
+		d.Check(i < 40, "scanned object")
+		p.scannedObjectList = append(p.scannedObjectList, int(i)%40)
+	}
+	d.Check(p.fifo.objectFetch >= -1 && p.fifo.objectFetch < len(p.scannedObjectList), "FIFO object fetch")
+}
@@ -0,0 +1,128 @@
+// Package rewind keeps recent save states in a bounded memory budget
+// to play the game backwards.
+//
+// Only the newest state is kept as it is. Older states are kept as the XOR
+// with the next newer state (mostly zeros, as few bytes change in a few frames)
+// compressed by flate.
+
+package rewind
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+const (
+	DefaultInterval = 4        // Frames between snapshots
+	DefaultBudget   = 32 << 20 // Bytes (about 1-2 minutes for most games)
+)
+
+type Buffer struct {
+	Interval int // Frames between snapshots
+	budget   int
+
+	latest []byte  // The newest state
+	deltas []delta // Oldest first. deltas[i] turns the state after it into the state before it.
+	size   int     // Bytes used by latest and deltas
+
+	zw *flate.Writer
+}
+
+type delta struct {
+	data   []byte // Compressed
+	isFull bool   // Not a XOR delta, but the compressed state itself (when the state size changed)
+}
+
+func NewBuffer(budget, interval int) *Buffer {
+	zw, _ := flate.NewWriter(nil, flate.BestSpeed)
+	return &Buffer{
+		Interval: interval,
+		budget:   budget,
+		zw:       zw,
+	}
+}
+
+// The Push adds the newest state. (The Buffer keeps state, do not modify it after Push.)
+func (b *Buffer) Push(state []byte) {
+	if b.latest != nil {
+		var d delta
+		if len(b.latest) == len(state) {
+			d.data = b.compress(xor(b.latest, state))
+		} else {
+			d.data = b.compress(b.latest)
+			d.isFull = true
+		}
+		b.deltas = append(b.deltas, d)
+		b.size += len(d.data) - len(b.latest)
+	}
+	b.latest = state
+	b.size += len(state)
+
+	// Forget the oldest states to stay within the budget.
+	drop := 0
+	for b.size > b.budget && drop < len(b.deltas) {
+		b.size -= len(b.deltas[drop].data)
+		drop++
+	}
+	b.deltas = b.deltas[drop:]
+}
+
+// The Pop removes the newest state and returns it.
+// It returns nil if there is no state.
+func (b *Buffer) Pop() []byte {
+	state := b.latest
+	if state == nil {
+		return nil
+	}
+	b.size -= len(state)
+	b.latest = nil
+	if n := len(b.deltas); n > 0 {
+		d := b.deltas[n-1]
+		b.deltas = b.deltas[:n-1]
+		b.size -= len(d.data)
+
+		prev := decompress(d.data)
+		if !d.isFull {
+			prev = xor(prev, state)
+		}
+		b.latest = prev
+		b.size += len(prev)
+	}
+	return state
+}
+
+// The Len returns the number of states in the buffer.
+func (b *Buffer) Len() int {
+	if b.latest == nil {
+		return 0
+	}
+	return len(b.deltas) + 1
+}
+
+func (b *Buffer) Clear() {
+	b.latest = nil
+	b.deltas = nil
+	b.size = 0
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+func (b *Buffer) compress(data []byte) []byte {
+	var buf bytes.Buffer
+	b.zw.Reset(&buf)
+	b.zw.Write(data)
+	b.zw.Close()
+	return buf.Bytes()
+}
+
+func decompress(data []byte) []byte {
+	out, _ := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	return out
+}
@@ -0,0 +1,100 @@
+package rewind
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// The makeStates returns states that change a little each time, like the snapshots of a game.
+func makeStates(n, size int) [][]byte {
+	r := rand.New(rand.NewSource(1))
+	states := make([][]byte, n)
+	cur := make([]byte, size)
+	r.Read(cur)
+	for i := range states {
+		for j := 0; j < 16; j++ {
+			cur[r.Intn(size)] = byte(r.Intn(256))
+		}
+		states[i] = bytes.Clone(cur)
+	}
+	return states
+}
+
+func TestPushPop(t *testing.T) {
+	states := makeStates(50, 4096)
+	b := NewBuffer(DefaultBudget, DefaultInterval)
+	for _, s := range states {
+		b.Push(bytes.Clone(s))
+	}
+	if b.Len() != len(states) {
+		t.Fatalf("Len = %d, want %d", b.Len(), len(states))
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if got := b.Pop(); !bytes.Equal(got, states[i]) {
+			t.Fatalf("state %d differs after Pop", i)
+		}
+	}
+	if b.Pop() != nil || b.Len() != 0 {
+		t.Error("Pop of an empty buffer returned a state")
+	}
+}
+
+// A state of another size is kept as a whole, not as a delta.
+func TestPushPopSizeChange(t *testing.T) {
+	states := [][]byte{
+		bytes.Repeat([]byte{1}, 100),
+		bytes.Repeat([]byte{2}, 200),
+		bytes.Repeat([]byte{3}, 200),
+		bytes.Repeat([]byte{4}, 50),
+	}
+	b := NewBuffer(DefaultBudget, DefaultInterval)
+	for _, s := range states {
+		b.Push(bytes.Clone(s))
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if got := b.Pop(); !bytes.Equal(got, states[i]) {
+			t.Fatalf("state %d = %v..., want %v...", i, got[:1], states[i][:1])
+		}
+	}
+}
+
+// The oldest states are forgotten to stay within the budget, and the newest ones are kept.
+func TestBudgetEvictsOldest(t *testing.T) {
+	const size = 4096
+	states := makeStates(200, size)
+	budget := 3 * size
+	b := NewBuffer(budget, DefaultInterval)
+	for _, s := range states {
+		b.Push(bytes.Clone(s))
+		if b.size > budget {
+			t.Fatalf("size = %d, over the budget %d", b.size, budget)
+		}
+	}
+	n := b.Len()
+	if n < 2 || n >= len(states) {
+		t.Fatalf("Len = %d, want some but not all of %d states", n, len(states))
+	}
+	for i := len(states) - 1; i >= len(states)-n; i-- {
+		if got := b.Pop(); !bytes.Equal(got, states[i]) {
+			t.Fatalf("state %d differs after Pop", i)
+		}
+	}
+	if b.Pop() != nil {
+		t.Error("more states than Len")
+	}
+	if b.size != 0 {
+		t.Errorf("size = %d after popping all states, want 0", b.size)
+	}
+}
+
+func TestClear(t *testing.T) {
+	b := NewBuffer(DefaultBudget, DefaultInterval)
+	for _, s := range makeStates(5, 256) {
+		b.Push(s)
+	}
+	b.Clear()
+	if b.Len() != 0 || b.Pop() != nil || b.size != 0 {
+		t.Error("states are left after Clear")
+	}
+}
@@ -0,0 +1,109 @@
+// Package savefile writes the battery save (.sav) file
+// soon after the game writes to the cartridge RAM, without waiting for the exit.
+
+package savefile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Flush 1 second after the last write. (Games write the save data in many small steps.)
+const DefaultDelayFrames = 60
+
+// The Data is the save data of the cartridge (memory.Memory).
+type Data interface {
+	GetSaveData() []byte
+	IsSaveDirty() bool
+	ClearSaveDirty()
+}
+
+// The Flusher writes the save data when the game has stopped writing to it for a while.
+type Flusher struct {
+	Path        string // Empty = Never write (e.g. while playing a movie)
+	DelayFrames int
+
+	data       Data
+	isPending  bool // Changed since the last flush
+	idleFrames int  // Frames since the last write
+}
+
+func NewFlusher(path string, data Data) *Flusher {
+	return &Flusher{
+		Path:        path,
+		DelayFrames: DefaultDelayFrames,
+		data:        data,
+	}
+}
+
+// The Update is called once per frame.
+func (f *Flusher) Update() error {
+	if f.data.IsSaveDirty() {
+		f.data.ClearSaveDirty()
+		f.isPending = true
+		f.idleFrames = 0
+		return nil
+	}
+	if !f.isPending {
+		return nil
+	}
+	f.idleFrames++
+	if f.idleFrames < f.DelayFrames {
+		return nil
+	}
+	return f.Flush()
+}
+
+// The MarkDirty makes the next Flush write the file. (e.g. after loading a save state)
+func (f *Flusher) MarkDirty() {
+	f.isPending = true
+	f.idleFrames = 0
+}
+
+// The Flush writes the save data now if it has been changed (on pause and exit).
+func (f *Flusher) Flush() error {
+	if f.data.IsSaveDirty() {
+		f.data.ClearSaveDirty()
+		f.isPending = true
+	}
+	if !f.isPending || f.Path == "" {
+		return nil
+	}
+	data := f.data.GetSaveData()
+	if len(data) == 0 {
+		f.isPending = false
+		return nil
+	}
+	if err := WriteFile(f.Path, data); err != nil {
+		return err
+	}
+	f.isPending = false
+	return nil
+}
+
+// The WriteFile writes to a temporary file and renames it,
+// so a crash while writing never leaves a broken save file.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails after the rename (nothing to remove).
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,170 @@
+package savefile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fakeData is the cartridge RAM written by a game.
+type fakeData struct {
+	data    []byte
+	isDirty bool
+}
+
+func (d *fakeData) GetSaveData() []byte { return d.data }
+func (d *fakeData) IsSaveDirty() bool   { return d.isDirty }
+func (d *fakeData) ClearSaveDirty()     { d.isDirty = false }
+
+func (d *fakeData) write(b byte) {
+	d.data[0] = b
+	d.isDirty = true
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The file is written DelayFrames after the last write.
+func TestFlusherDebounce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	data := &fakeData{data: make([]byte, 4)}
+	f := NewFlusher(path, data)
+
+	update := func() {
+		if err := f.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data.write(1)
+	update()
+	for i := 0; i < DefaultDelayFrames-1; i++ {
+		update()
+	}
+	if readFile(t, path) != nil {
+		t.Fatal("written before the delay")
+	}
+
+	// Another write restarts the delay.
+	data.write(2)
+	update()
+	for i := 0; i < DefaultDelayFrames-1; i++ {
+		update()
+	}
+	if readFile(t, path) != nil {
+		t.Fatal("written before the delay after the last write")
+	}
+	update()
+	if got := readFile(t, path); !bytes.Equal(got, []byte{2, 0, 0, 0}) {
+		t.Fatalf("file = %v, want the last data", got)
+	}
+
+	// Nothing is written again without a change.
+	os.Remove(path)
+	for i := 0; i < 2*DefaultDelayFrames; i++ {
+		update()
+	}
+	if readFile(t, path) != nil {
+		t.Error("written without a change")
+	}
+}
+
+func TestFlusherFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	data := &fakeData{data: make([]byte, 4)}
+	f := NewFlusher(path, data)
+
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, path) != nil {
+		t.Fatal("written without a change")
+	}
+
+	data.write(3)
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); !bytes.Equal(got, []byte{3, 0, 0, 0}) {
+		t.Fatalf("file = %v, want the data at once", got)
+	}
+
+	// MarkDirty writes the data even if the game has not written to it (e.g. after loading a state).
+	os.Remove(path)
+	f.MarkDirty()
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, path) == nil {
+		t.Error("not written after MarkDirty")
+	}
+}
+
+func TestFlusherWithoutPath(t *testing.T) {
+	dir := t.TempDir()
+	data := &fakeData{data: make([]byte, 4)}
+	f := NewFlusher("", data)
+	data.write(1)
+	for i := 0; i < 2*DefaultDelayFrames; i++ {
+		if err := f.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files written: %v", entries)
+	}
+}
+
+// The WriteFile replaces the file by renaming, and leaves no temporary file.
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "game.sav")
+	if err := WriteFile(path, []byte("old data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); string(got) != "new" {
+		t.Errorf("file = %q, want %q", got, "new")
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "game.sav" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("files = %v, want only game.sav", names)
+	}
+}
+
+// If the file cannot be written, the old one is kept.
+func TestWriteFileKeepsOldFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "game.sav")
+	if err := WriteFile(path, []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	// The save file is in the way of the directory, so the write fails.
+	if err := WriteFile(filepath.Join(path, "x.sav"), []byte("new")); err == nil {
+		t.Fatal("no error for a path under a file")
+	}
+	if got := readFile(t, path); string(got) != "old" {
+		t.Errorf("file = %q, want %q", got, "old")
+	}
+}
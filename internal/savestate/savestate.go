@@ -0,0 +1,85 @@
+// Package savestate provides the little-endian encoder/decoder
+// that every component uses to snapshot its internal state.
+
+package savestate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// The Encoder remembers the first error, so components can write
+// all their fields without checking an error after each one.
+type Encoder struct {
+	w   io.Writer
+	err error
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// The Write writes fixed-size data (bytes, bools, sized ints, floats, and arrays or slices of them).
+func (e *Encoder) Write(v any) {
+	if e.err != nil {
+		return
+	}
+	e.err = binary.Write(e.w, binary.LittleEndian, v)
+}
+
+// The WriteInt writes an int as int64 so the size does not depend on the platform.
+func (e *Encoder) WriteInt(v int) {
+	e.Write(int64(v))
+}
+
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// The Decoder is the counterpart of the Encoder.
+type Decoder struct {
+	r   io.Reader
+	err error
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// The Read reads fixed-size data into v (must be a pointer or a slice).
+func (d *Decoder) Read(v any) {
+	if d.err != nil {
+		return
+	}
+	d.err = binary.Read(d.r, binary.LittleEndian, v)
+}
+
+// The ReadInt reads an int written by Encoder.WriteInt.
+func (d *Decoder) ReadInt(v *int) {
+	var n int64
+	d.Read(&n)
+	*v = int(n)
+}
+
+// The Check reports a RangeError for the value called name unless ok.
+// Components check the values that index their arrays or drive their loops,
+// so a broken state is rejected by the loader instead of crashing a later frame.
+func (d *Decoder) Check(ok bool, name string) {
+	if d.err == nil && !ok {
+		d.err = &RangeError{Name: name}
+	}
+}
+
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+// The RangeError is reported by Decoder.Check.
+type RangeError struct {
+	Name string
+}
+
+func (err *RangeError) Error() string {
+	return fmt.Sprintf("%s is out of range", err.Name)
+}
@@ -0,0 +1,119 @@
+package savestate
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	type values struct {
+		b   byte
+		u16 uint16
+		u32 uint32
+		f   float64
+		ok  bool
+		n   int
+		arr [3]byte
+		s   []uint16
+	}
+	want := values{b: 0x12, u16: 0x3456, u32: 0x789ABCDE, f: 1.5, ok: true, n: -12345, arr: [3]byte{1, 2, 3}, s: []uint16{0xFFFF, 0}}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.Write(want.b)
+	e.Write(want.u16)
+	e.Write(want.u32)
+	e.Write(want.f)
+	e.Write(want.ok)
+	e.WriteInt(want.n)
+	e.Write(want.arr)
+	e.Write(want.s)
+	if e.Err() != nil {
+		t.Fatal(e.Err())
+	}
+	if n := buf.Len(); n != 1+2+4+8+1+8+3+4 {
+		t.Errorf("encoded size = %d", n)
+	}
+	if got := buf.Bytes()[1:3]; !bytes.Equal(got, []byte{0x56, 0x34}) {
+		t.Errorf("uint16 is not little-endian: % X", got)
+	}
+
+	got := values{s: make([]uint16, 2)}
+	d := NewDecoder(&buf)
+	d.Read(&got.b)
+	d.Read(&got.u16)
+	d.Read(&got.u32)
+	d.Read(&got.f)
+	d.Read(&got.ok)
+	d.ReadInt(&got.n)
+	d.Read(&got.arr)
+	d.Read(got.s)
+	if d.Err() != nil {
+		t.Fatal(d.Err())
+	}
+	if got.b != want.b || got.u16 != want.u16 || got.u32 != want.u32 || got.f != want.f ||
+		got.ok != want.ok || got.n != want.n || got.arr != want.arr || got.s[0] != want.s[0] || got.s[1] != want.s[1] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// The first error is kept, and the later calls do nothing.
+func TestDecoderStickyError(t *testing.T) {
+	d := NewDecoder(bytes.NewReader([]byte{1, 2, 3}))
+	var v uint32
+	d.Read(&v)
+	if !errors.Is(d.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("err = %v, want io.ErrUnexpectedEOF", d.Err())
+	}
+	var b byte = 0x55
+	d.Read(&b)
+	if b != 0x55 || !errors.Is(d.Err(), io.ErrUnexpectedEOF) {
+		t.Errorf("read after an error: b = %#x, err = %v", b, d.Err())
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, io.ErrShortWrite
+}
+
+func TestEncoderStickyError(t *testing.T) {
+	w := &failingWriter{}
+	e := NewEncoder(w)
+	e.Write(uint32(1))
+	e.Write(uint32(2))
+	e.WriteInt(3)
+	if !errors.Is(e.Err(), io.ErrShortWrite) {
+		t.Errorf("err = %v, want io.ErrShortWrite", e.Err())
+	}
+	if w.n != 1 {
+		t.Errorf("writer called %d times after an error, want 1", w.n)
+	}
+}
+
+func TestDecoderCheck(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(nil))
+	d.Check(true, "a")
+	if d.Err() != nil {
+		t.Fatalf("err = %v after a passed check", d.Err())
+	}
+	d.Check(false, "b")
+	d.Check(false, "c")
+	var rangeErr *RangeError
+	if !errors.As(d.Err(), &rangeErr) || rangeErr.Name != "b" {
+		t.Errorf("err = %v, want the RangeError of b", d.Err())
+	}
+
+	// A read error comes first.
+	d = NewDecoder(bytes.NewReader(nil))
+	var b byte
+	d.Read(&b)
+	d.Check(false, "b")
+	if !errors.Is(d.Err(), io.EOF) {
+		t.Errorf("err = %v, want io.EOF", d.Err())
+	}
+}
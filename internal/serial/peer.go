@@ -0,0 +1,71 @@
+package serial
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// The Peer is the device at the other end of the link cable.
+type Peer interface {
+	// The Exchange is called when a transfer with the internal clock starts.
+	// out is the byte to be shifted out of SB, and the return value is shifted in.
+	Exchange(out byte) byte
+}
+
+// The NullPeer behaves as if no cable is connected.
+// (The input line is pulled up, so 0xFF is received.)
+type NullPeer struct{}
+
+func (NullPeer) Exchange(out byte) byte {
+	return 0xFF
+}
+
+// The LogPeer writes every byte sent to W, and receives 0xFF.
+type LogPeer struct {
+	W io.Writer
+}
+
+func (p *LogPeer) Exchange(out byte) byte {
+	fmt.Fprintf(p.W, "serial: %02X\n", out)
+	return 0xFF
+}
+
+// The linkPeer is the end of the cable connected to another Serial.
+type linkPeer struct {
+	remote *Serial
+}
+
+// If the remote side is waiting for an external clock, both bytes are swapped.
+// Otherwise, nothing is listening, and 0xFF is received.
+func (p *linkPeer) Exchange(out byte) byte {
+	if !p.remote.isWaitingForExternalClock() {
+		return 0xFF
+	}
+	return p.remote.receive(out)
+}
+
+// The Connect links the serial ports of two emulators with a cable.
+func Connect(a, b *Serial) {
+	a.Peer = &linkPeer{remote: b}
+	b.Peer = &linkPeer{remote: a}
+}
+
+// The CapturePeer keeps every byte sent, such as the results printed by test ROMs.
+type CapturePeer struct {
+	W   io.Writer // If not nil, the bytes are also written to W (e.g. os.Stdout).
+	buf bytes.Buffer
+}
+
+func (p *CapturePeer) Exchange(out byte) byte {
+	p.buf.WriteByte(out)
+	if p.W != nil {
+		p.W.Write([]byte{out})
+	}
+	return 0xFF
+}
+
+// The Output returns all the bytes sent so far.
+func (p *CapturePeer) Output() string {
+	return p.buf.String()
+}
@@ -0,0 +1,125 @@
+package serial
+
+import "gomeboy/internal/savestate"
+
+// With the internal clock, one bit is shifted every 512 CPU cycles (8192Hz).
+// (In CGB double speed mode, the CPU cycles are also doubled, so it becomes 16384Hz.)
+const (
+	CyclesPerBit     = 512
+	CyclesPerBitFast = 16 // CGB SC.1 (262144Hz)
+)
+
+type Serial struct {
+	Peer Peer // The device at the other end of the link cable
+
+	// I/O Registers
+	sb byte
+	sc byte
+
+	// Transfer in progress
+	bitsLeft int
+	cycles   int
+	inByte   byte // The byte being shifted in from the peer
+
+	// Others
+	HasIRQ bool
+	IsCGB  bool
+}
+
+func NewSerial() *Serial {
+	return &Serial{
+		Peer: NullPeer{},
+	}
+}
+
+// The Step shifts one bit of SB every bit period of the internal clock,
+// and requests the serial interrupt when all 8 bits have been shifted.
+func (s *Serial) Step(cpuCycles int) {
+	if s.bitsLeft == 0 {
+		return
+	}
+	s.cycles += cpuCycles
+	period := s.getCyclesPerBit()
+	for s.bitsLeft > 0 && s.cycles >= period {
+		s.cycles -= period
+		s.bitsLeft--
+		s.sb = s.sb<<1 | (s.inByte>>s.bitsLeft)&1
+		if s.bitsLeft == 0 {
+			s.complete()
+		}
+	}
+}
+
+func (s *Serial) getCyclesPerBit() int {
+	if s.IsCGB && s.sc&(1<<1) != 0 {
+		return CyclesPerBitFast
+	}
+	return CyclesPerBit
+}
+
+func (s *Serial) complete() {
+	s.sc &^= 1 << 7
+	s.cycles = 0
+	s.HasIRQ = true
+}
+
+// The isWaitingForExternalClock reports whether a transfer has been requested
+// and is waiting for the other side to drive the clock.
+func (s *Serial) isWaitingForExternalClock() bool {
+	return s.sc&(1<<7) != 0 && s.sc&(1<<0) == 0
+}
+
+// The receive completes a transfer clocked by the other side at once.
+// It returns the byte that was in SB.
+func (s *Serial) receive(in byte) byte {
+	out := s.sb
+	s.sb = in
+	s.complete()
+	return out
+}
+
+func (s *Serial) GetSB() byte {
+	return s.sb
+}
+
+func (s *Serial) SetSB(val byte) {
+	s.sb = val
+}
+
+func (s *Serial) GetSC() byte {
+	if s.IsCGB {
+		return 0x7C | s.sc&0x83
+	}
+	return 0x7E | s.sc&0x81
+}
+
+// Writing SC.7=1 with SC.0=1 (internal clock) starts a transfer.
+// With SC.0=0 (external clock), the transfer waits for the other side.
+func (s *Serial) SetSC(val byte) {
+	s.sc = val & 0x83
+	s.bitsLeft = 0
+	s.cycles = 0
+	if s.sc&0x81 == 0x81 {
+		s.bitsLeft = 8
+		s.inByte = s.Peer.Exchange(s.sb)
+	}
+}
+
+func (s *Serial) SaveState(e *savestate.Encoder) {
+	e.Write(s.sb)
+	e.Write(s.sc)
+	e.WriteInt(s.bitsLeft)
+	e.WriteInt(s.cycles)
+	e.Write(s.inByte)
+	e.Write(s.HasIRQ)
+}
+
+func (s *Serial) LoadState(d *savestate.Decoder) {
+	d.Read(&s.sb)
+	d.Read(&s.sc)
+	d.ReadInt(&s.bitsLeft)
+	d.Check(s.bitsLeft >= 0 && s.bitsLeft <= 8, "serial bits")
+	d.ReadInt(&s.cycles)
+	d.Read(&s.inByte)
+	d.Read(&s.HasIRQ)
+}
@@ -0,0 +1,144 @@
+package serial
+
+import "testing"
+
+// The fixedPeer records the bytes sent, and always answers with the same byte.
+type fixedPeer struct {
+	in   byte
+	sent []byte
+}
+
+func (p *fixedPeer) Exchange(out byte) byte {
+	p.sent = append(p.sent, out)
+	return p.in
+}
+
+func TestTransferTiming(t *testing.T) {
+	tests := []struct {
+		name  string
+		isCGB bool
+		sc    byte
+		total int // CPU cycles until the transfer completes
+	}{
+		{name: "DMG", isCGB: false, sc: 0x81, total: 8 * CyclesPerBit},
+		{name: "DMG ignores the fast bit", isCGB: false, sc: 0x83, total: 8 * CyclesPerBit},
+		{name: "CGB normal", isCGB: true, sc: 0x81, total: 8 * CyclesPerBit},
+		{name: "CGB fast", isCGB: true, sc: 0x83, total: 8 * CyclesPerBitFast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := &fixedPeer{in: 0xA5}
+			s := NewSerial()
+			s.IsCGB = tt.isCGB
+			s.Peer = peer
+			s.SetSB(0x3C)
+			s.SetSC(tt.sc)
+			if len(peer.sent) != 1 || peer.sent[0] != 0x3C {
+				t.Fatalf("sent = % X, want 3C", peer.sent)
+			}
+
+			for c := 0; c < tt.total-4; c += 4 {
+				s.Step(4)
+			}
+			if s.GetSC()&0x80 == 0 || s.HasIRQ {
+				t.Fatalf("completed before %d cycles", tt.total)
+			}
+			s.Step(4)
+			if s.GetSC()&0x80 != 0 {
+				t.Errorf("SC.7 is still set after %d cycles", tt.total)
+			}
+			if !s.HasIRQ {
+				t.Error("no serial interrupt")
+			}
+			if s.GetSB() != 0xA5 {
+				t.Errorf("SB = %02X, want A5", s.GetSB())
+			}
+		})
+	}
+}
+
+// The bits of the received byte are shifted in from the MSB, one per bit period.
+func TestTransferShiftsBits(t *testing.T) {
+	s := NewSerial()
+	s.Peer = &fixedPeer{in: 0x00}
+	s.SetSB(0xFF)
+	s.SetSC(0x81)
+	want := []byte{0xFE, 0xFC, 0xF8, 0xF0, 0xE0, 0xC0, 0x80, 0x00}
+	for i, w := range want {
+		s.Step(CyclesPerBit)
+		if s.GetSB() != w {
+			t.Errorf("SB after bit %d = %02X, want %02X", i+1, s.GetSB(), w)
+		}
+	}
+}
+
+// With the external clock, nothing happens until the other side drives the clock.
+func TestExternalClockWaits(t *testing.T) {
+	peer := &fixedPeer{in: 0x00}
+	s := NewSerial()
+	s.Peer = peer
+	s.SetSB(0x12)
+	s.SetSC(0x80)
+	s.Step(100 * CyclesPerBit)
+	if len(peer.sent) != 0 || s.HasIRQ || s.GetSC()&0x80 == 0 || s.GetSB() != 0x12 {
+		t.Errorf("transfer progressed without a clock: sent = % X, SC = %02X, SB = %02X", peer.sent, s.GetSC(), s.GetSB())
+	}
+}
+
+func TestGetSC(t *testing.T) {
+	tests := []struct {
+		isCGB bool
+		val   byte
+		want  byte
+	}{
+		{isCGB: false, val: 0x00, want: 0x7E},
+		{isCGB: false, val: 0xFF, want: 0xFF},
+		{isCGB: false, val: 0x02, want: 0x7E},
+		{isCGB: true, val: 0x00, want: 0x7C},
+		{isCGB: true, val: 0x02, want: 0x7E},
+	}
+	for _, tt := range tests {
+		s := NewSerial()
+		s.IsCGB = tt.isCGB
+		s.SetSC(tt.val)
+		if got := s.GetSC(); got != tt.want {
+			t.Errorf("CGB=%v: SC after writing %02X = %02X, want %02X", tt.isCGB, tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name        string
+		isListening bool // The other side has set SC to 0x80 (external clock).
+		wantA       byte
+		wantB       byte
+	}{
+		{name: "listening", isListening: true, wantA: 0x34, wantB: 0x12},
+		{name: "not listening", isListening: false, wantA: 0x12, wantB: 0xFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := NewSerial(), NewSerial()
+			Connect(a, b)
+			a.SetSB(0x12)
+			if tt.isListening {
+				a.SetSC(0x80)
+			}
+			b.SetSB(0x34)
+			b.SetSC(0x81)
+
+			// The external side completes as soon as the clock starts.
+			if tt.isListening && (!a.HasIRQ || a.GetSC()&0x80 != 0) {
+				t.Error("the listening side did not complete")
+			}
+			b.Step(8 * CyclesPerBit)
+			if !b.HasIRQ {
+				t.Error("the clocking side did not complete")
+			}
+			if a.GetSB() != tt.wantA || b.GetSB() != tt.wantB {
+				t.Errorf("SB = %02X, %02X, want %02X, %02X", a.GetSB(), b.GetSB(), tt.wantA, tt.wantB)
+			}
+		})
+	}
+}
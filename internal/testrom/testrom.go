@@ -0,0 +1,87 @@
+// Package testrom judges the results of Blargg/Mooneye-style test ROMs.
+package testrom
+
+import (
+	"gomeboy/internal/emulator"
+	"gomeboy/internal/serial"
+	"io"
+	"strings"
+)
+
+type Result int
+
+const (
+	Running Result = iota
+	Passed
+	Failed
+	TimedOut
+)
+
+func (r Result) String() string {
+	switch r {
+	case Running:
+		return "running"
+	case Passed:
+		return "passed"
+	case Failed:
+		return "failed"
+	case TimedOut:
+		return "timed out"
+	}
+	return "unknown"
+}
+
+// The Checker watches the serial output (Blargg)
+// and the registers at LD B, B (Mooneye).
+type Checker struct {
+	Serial *serial.CapturePeer
+	emu    *emulator.Emulator
+}
+
+// The NewChecker connects a CapturePeer to the serial port of emu.
+// If w is not nil, the serial output is also written to w.
+func NewChecker(emu *emulator.Emulator, w io.Writer) *Checker {
+	c := &Checker{
+		Serial: &serial.CapturePeer{W: w},
+		emu:    emu,
+	}
+	emu.CPU.Bus.Serial.Peer = c.Serial
+	return c
+}
+
+func (c *Checker) Check() Result {
+	// Blargg's ROMs print "Passed" or "Failed" at the end.
+	out := c.Serial.Output()
+	switch {
+	case strings.Contains(out, "Passed"):
+		return Passed
+	case strings.Contains(out, "Failed"):
+		return Failed
+	}
+
+	// Mooneye's ROMs load the Fibonacci numbers (or 0x42 on failure) and execute LD B, B.
+	// Other ROMs may execute LD B, B as an ordinary instruction, so the registers are judged
+	// only as they were at the last LD B, B.
+	if r, ok := c.emu.CPU.TakeBreakpoint(); ok {
+		switch {
+		case r.B == 3 && r.C == 5 && r.D == 8 && r.E == 13 && r.H == 21 && r.L == 34:
+			return Passed
+		case r.B == 0x42 && r.C == 0x42 && r.D == 0x42 && r.E == 0x42 && r.H == 0x42 && r.L == 0x42:
+			return Failed
+		}
+	}
+	return Running
+}
+
+// The Run runs up to maxFrames frames until the result is known.
+func (c *Checker) Run(maxFrames int) Result {
+	for i := 0; i < maxFrames; i++ {
+		if c.emu.RunFrame() == -1 {
+			return Failed
+		}
+		if r := c.Check(); r != Running {
+			return r
+		}
+	}
+	return TimedOut
+}
@@ -0,0 +1,27 @@
+package timer
+
+import "gomeboy/internal/savestate"
+
+func (t *Timer) SaveState(e *savestate.Encoder) {
+	e.Write(t.tma)
+	e.Write(t.tima)
+	e.Write(t.tac)
+	e.Write(t.divCounter)
+	e.Write(t.prevDIV)
+	e.Write(t.isOverflow)
+	e.WriteInt(t.cycleCount)
+	e.Write(t.HasIRQ)
+	e.Write(t.isPrevCPUStopped)
+}
+
+func (t *Timer) LoadState(d *savestate.Decoder) {
+	d.Read(&t.tma)
+	d.Read(&t.tima)
+	d.Read(&t.tac)
+	d.Read(&t.divCounter)
+	d.Read(&t.prevDIV)
+	d.Read(&t.isOverflow)
+	d.ReadInt(&t.cycleCount)
+	d.Read(&t.HasIRQ)
+	d.Read(&t.isPrevCPUStopped)
+}